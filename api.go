@@ -3,85 +3,171 @@ package dsk
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
 )
 
 const (
 	BaseURL = "https://chat.deepseek.com/api/v0"
+
+	// requestIDHeader WithRequestIDFunc 生成的请求 id 使用的请求头
+	requestIDHeader = "x-request-id"
 )
 
 // DeepSeekAPI DeepSeek API 客户端
+//
+// 并发约定：同一个 *DeepSeekAPI 可以被多个 goroutine 同时使用，所有方法都是并发安全的。
+// 嵌入的 WASM 实例不支持并发调用，默认的求解器内部加锁串行求解，并发请求的 PoW 会排队，
+// 需要并行求解时使用 WithPoWConcurrency；通过 WithPoWSolver 传入的求解器必须自行保证并发安全。
+// 单个响应流（chunkChan/errChan）和 Conversation 的同一轮对话应只由一个 goroutine 读取
 type DeepSeekAPI struct {
-	authToken string
-	powSolver *DeepSeekPOW
-	client    *http.Client
+	tokenMu       sync.RWMutex
+	authToken     string
+	tokenProvider func(ctx context.Context) (string, error)
+	tokenSelector TokenSelector
+	sessionTokens *sessionTokenCache // 会话 ID -> 创建该会话的 token，见 NewDeepSeekAPIWithTokens
+
+	powSolver     PoWSolver
+	powAlgorithms map[string]PoWSolver // WithPoWAlgorithm 注册的其他算法
+	powCache      *powCache
+	powStats      powStats
+	ownedPoW      *PoWPool // 客户端自己创建的 WASM 求解器，Close 时释放
+	powInstances  int      // WithPoWConcurrency 设置的 WASM 实例数
+	client        *http.Client
+	customClient  bool             // client 由 WithHTTPClient 提供
+	transport     transportOptions // 见 transport.go
+	baseURL       string
+	log           levelLogger
+	metrics       MetricsHook
+
+	requestInterceptors  []RequestInterceptor
+	responseInterceptors []ResponseInterceptor
+	requestIDFunc        func() string
+
+	streamIdleTimeout time.Duration
+	streamReconnects  int
+	completionTimeout time.Duration
+	maxLineSize       int
+	maxParseErrors    int
+	maxPromptTokens   int
+	truncateSide      TruncateSide
+	systemPrompt      string
+	autoSession       bool
+	defaultThinking   bool
+	defaultSearch     bool
+
+	maxUploadSize      int64
+	allowedUploadTypes []string
+
+	wazeroConfig     wazero.RuntimeConfig
+	expectedWASMHash string
+
+	// 以下字段用于 Close 时等待进行中的请求，见 lifecycle.go
+	closeMu  sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+	shutdown context.Context
+	cancel   context.CancelFunc
 }
 
 // NewDeepSeekAPI 创建新的 API 客户端
 // WASM 文件已嵌入到二进制中，无需指定路径
-func NewDeepSeekAPI(authToken string) (*DeepSeekAPI, error) {
-	if authToken == "" {
-		return nil, fmt.Errorf("auth token cannot be empty")
-	}
-
+func NewDeepSeekAPI(authToken string, opts ...Option) (*DeepSeekAPI, error) {
 	// 使用嵌入的 WASM 文件
-	powSolver, err := NewDeepSeekPOW("")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create PoW solver: %w", err)
-	}
-
-	return &DeepSeekAPI{
-		authToken: authToken,
-		powSolver: powSolver,
-		client: &http.Client{
-			Timeout: 0, // 无超时，用于长连接
-		},
-	}, nil
+	return newDeepSeekAPI(authToken, "", opts)
 }
 
 // NewDeepSeekAPIWithCustomWASM 使用自定义 WASM 文件创建 API 客户端
 // 仅在需要测试或使用自定义 WASM 文件时使用
-func NewDeepSeekAPIWithCustomWASM(authToken string, wasmPath string) (*DeepSeekAPI, error) {
-	if authToken == "" {
-		return nil, fmt.Errorf("auth token cannot be empty")
-	}
-
+func NewDeepSeekAPIWithCustomWASM(authToken string, wasmPath string, opts ...Option) (*DeepSeekAPI, error) {
 	if wasmPath == "" {
 		return nil, fmt.Errorf("wasm path cannot be empty when using custom WASM")
 	}
 
-	powSolver, err := NewDeepSeekPOW(wasmPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create PoW solver: %w", err)
-	}
+	return newDeepSeekAPI(authToken, wasmPath, opts)
+}
 
-	return &DeepSeekAPI{
+// newDeepSeekAPI 应用选项并创建客户端，wasmPath 为空时使用嵌入的 WASM 文件
+// 设置了 WithTokenProvider 时 authToken 可以为空，首次请求时再获取
+func newDeepSeekAPI(authToken string, wasmPath string, opts []Option) (*DeepSeekAPI, error) {
+	api := &DeepSeekAPI{
 		authToken: authToken,
-		powSolver: powSolver,
 		client: &http.Client{
 			Timeout: 0, // 无超时，用于长连接
 		},
-	}, nil
+		baseURL: BaseURL,
+		log: levelLogger{
+			Logger: defaultLogger{},
+			level:  LevelDebug,
+		},
+		metrics:  noopMetrics{},
+		powCache: newPoWCache(defaultPoWCacheSize),
+
+		sessionTokens: newSessionTokenCache(sessionTokenCacheSize),
+
+		maxUploadSize:      DefaultMaxUploadSize,
+		allowedUploadTypes: DefaultUploadTypes,
+	}
+	api.shutdown, api.cancel = context.WithCancel(context.Background())
+
+	for _, opt := range opts {
+		opt(api)
+	}
+
+	if api.authToken == "" && api.tokenProvider == nil && api.tokenSelector == nil {
+		return nil, fmt.Errorf("auth token cannot be empty")
+	}
+	if err := api.configureTransport(); err != nil {
+		return nil, err
+	}
+
+	// 通过 WithPoWSolver 提供了求解器时不需要创建 WASM 运行时
+	if api.powSolver == nil {
+		powSolver, err := newPoWPool(api.powInstances, wasmPath, powConfig{
+			runtimeConfig: api.wazeroConfig,
+			logger:        api.log,
+			expectedHash:  api.expectedWASMHash,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create PoW solver: %w", err)
+		}
+		api.ownedPoW = powSolver
+
+		registry := NewPoWRegistry()
+		registry.Register(AlgorithmDeepSeekHashV1, powSolver)
+		for algorithm, solver := range api.powAlgorithms {
+			registry.Register(algorithm, solver)
+		}
+		api.powSolver = registry
+	}
+
+	return api, nil
 }
 
-// Close 清理资源
-func (api *DeepSeekAPI) Close() error {
-	if api.powSolver != nil {
-		return api.powSolver.Close()
+// WASMSHA256 返回客户端加载的 WASM 文件的 SHA-256，通过 WithPoWSolver 传入求解器时返回空字符串
+func (api *DeepSeekAPI) WASMSHA256() string {
+	if api.ownedPoW == nil {
+		return ""
 	}
-	return nil
+	return api.ownedPoW.WASMSHA256()
 }
 
-// getHeaders 获取请求头
+// getHeaders 获取请求头，authorization 由 do 在发送时根据当前 token 设置
 func (api *DeepSeekAPI) getHeaders(powResponse string) map[string]string {
 	headers := map[string]string{
 		"accept":            "*/*",
 		"accept-language":   "en,fr-FR;q=0.9,fr;q=0.8,es-ES;q=0.7,es;q=0.6,en-US;q=0.5,am;q=0.4,de;q=0.3",
-		"authorization":     fmt.Sprintf("Bearer %s", api.authToken),
 		"content-type":      "application/json",
 		"origin":            "https://chat.deepseek.com",
 		"referer":           "https://chat.deepseek.com/",
@@ -99,12 +185,29 @@ func (api *DeepSeekAPI) getHeaders(powResponse string) map[string]string {
 	return headers
 }
 
-// getPowChallenge 获取 PoW 挑战
-func (api *DeepSeekAPI) getPowChallenge() (ChallengeConfig, error) {
-	url := fmt.Sprintf("%s/chat/create_pow_challenge", BaseURL)
+// powTargetPath 返回接口在服务端的完整路径，PoW 挑战与接口路径绑定
+// endpoint 为空时使用对话接口；查询参数不属于路径，不参与绑定
+func powTargetPath(endpoint string) string {
+	if endpoint == "" {
+		endpoint = completionEndpoint
+	}
+	return "/api/v0" + stripQuery(endpoint)
+}
+
+// stripQuery 去掉 endpoint 中的查询参数
+func stripQuery(endpoint string) string {
+	if i := strings.IndexByte(endpoint, '?'); i >= 0 {
+		return endpoint[:i]
+	}
+	return endpoint
+}
+
+// getPowChallenge 获取用于 endpoint 接口的 PoW 挑战
+func (api *DeepSeekAPI) getPowChallenge(ctx context.Context, endpoint string) (ChallengeConfig, error) {
+	url := fmt.Sprintf("%s/chat/create_pow_challenge", api.baseURL)
 
 	reqBody := map[string]interface{}{
-		"target_path": "/api/v0/chat/completion",
+		"target_path": powTargetPath(endpoint),
 	}
 
 	jsonData, err := json.Marshal(reqBody)
@@ -112,7 +215,7 @@ func (api *DeepSeekAPI) getPowChallenge() (ChallengeConfig, error) {
 		return ChallengeConfig{}, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return ChallengeConfig{}, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -121,7 +224,7 @@ func (api *DeepSeekAPI) getPowChallenge() (ChallengeConfig, error) {
 		req.Header.Set(k, v)
 	}
 
-	resp, err := api.client.Do(req)
+	resp, err := api.do(req, "/chat/create_pow_challenge")
 	if err != nil {
 		return ChallengeConfig{}, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -129,7 +232,7 @@ func (api *DeepSeekAPI) getPowChallenge() (ChallengeConfig, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return ChallengeConfig{}, fmt.Errorf("failed to get challenge: status %d, body: %s", resp.StatusCode, string(body))
+		return ChallengeConfig{}, fmt.Errorf("failed to get challenge: %w", newAPIError("/chat/create_pow_challenge", resp, body))
 	}
 
 	var result struct {
@@ -147,29 +250,193 @@ func (api *DeepSeekAPI) getPowChallenge() (ChallengeConfig, error) {
 	return result.Data.BizData.Challenge, nil
 }
 
-// makeRequest 发送 HTTP 请求
-func (api *DeepSeekAPI) makeRequest(method, endpoint string, jsonData map[string]interface{}, powRequired bool) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s%s", BaseURL, endpoint)
+// do 设置认证信息后发送 HTTP 请求，endpoint 为不带 BaseURL 的接口路径
+// 设置了 WithTokenProvider 时，收到 401 会刷新一次 token 并重发请求
+func (api *DeepSeekAPI) do(req *http.Request, endpoint string) (*http.Response, error) {
+	if api.requestIDFunc != nil {
+		if id := api.requestIDFunc(); id != "" {
+			req.Header.Set(requestIDHeader, id)
+			api.log.Debugf("Request %s %s: %s=%s", req.Method, endpoint, requestIDHeader, id)
+		}
+	}
+
+	token, err := api.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("authorization", "Bearer "+token)
 
-	var powResponse string
-	if powRequired {
-		challenge, err := api.getPowChallenge()
+	resp, err := api.send(req, endpoint)
+	if err == nil && api.tokenSelector != nil {
+		api.tokenSelector.Report(token, resp.StatusCode)
+	}
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || api.tokenProvider == nil || api.tokenSelector != nil {
+		return resp, err
+	}
+
+	// 请求体已经被读取且无法重放（例如 UploadFile 流式读取的文件）时不能重发，
+	// 只刷新 token 供后续请求使用，把 401 响应原样返回给调用方
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		api.log.Infof("Request to %s returned 401, refreshing token without retry: request body cannot be rewound", endpoint)
+		if _, err := api.refreshToken(req.Context(), token); err != nil {
+			api.log.Warnf("Failed to refresh token after 401 from %s: %v", endpoint, err)
+		}
+		return resp, nil
+	}
+
+	// token 已失效，刷新后重发一次
+	resp.Body.Close()
+	api.log.Infof("Request to %s returned 401, refreshing token", endpoint)
+
+	token, err = api.refreshToken(req.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("authorization", "Bearer "+token)
+
+	return api.send(retry, endpoint)
+}
+
+// send 执行拦截器、发送请求并记录请求指标
+func (api *DeepSeekAPI) send(req *http.Request, endpoint string) (*http.Response, error) {
+	for _, intercept := range api.requestInterceptors {
+		if err := intercept(req); err != nil {
+			return nil, fmt.Errorf("request interceptor: %w", err)
+		}
+	}
+
+	start := time.Now()
+	resp, err := api.client.Do(req)
+
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	api.metrics.OnRequest(endpoint, time.Since(start), status)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := decodeContentEncoding(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	for _, intercept := range api.responseInterceptors {
+		if err := intercept(resp); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("response interceptor: %w", err)
+		}
+	}
+
+	return resp, nil
+}
+
+// solvePow 获取并解决 endpoint 接口的 PoW 挑战，返回 x-ds-pow-response 请求头的值
+// 所有失败都以 *PoWError 返回
+func (api *DeepSeekAPI) solvePow(ctx context.Context, endpoint string) (string, error) {
+	// BatchComplete 预先求解的结果，挑战过期时按正常流程重新获取
+	if p, ok := ctx.Value(preparedPoWKey{}).(preparedPoW); ok && p.endpoint == endpoint && !challengeExpired(p.challenge, time.Now()) {
+		return p.response, nil
+	}
+
+	for attempt := 1; ; attempt++ {
+		challenge, err := api.getPowChallenge(ctx, endpoint)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get PoW challenge: %w", err)
+			return "", &PoWError{Stage: PoWStageFetch, Err: err}
+		}
+
+		// 挑战已经过期或即将过期（例如时钟偏差）时重新获取一次，不浪费一次求解
+		if attempt == 1 && challengeExpired(challenge, time.Now().Add(powExpiryMargin)) {
+			api.log.Infof("PoW challenge expires within %s, fetching a new one", powExpiryMargin)
+			continue
+		}
+		if err := validateChallenge(challenge, time.Now()); err != nil {
+			return "", &PoWError{Stage: PoWStageFetch, Err: err}
 		}
 
-		powResponse, err = api.powSolver.SolveChallenge(challenge)
+		// 重试时可能拿到同一个挑战，直接使用缓存的结果
+		if powResponse, ok := api.powCache.get(challenge, time.Now()); ok {
+			api.log.Debugf("Using cached PoW response")
+			return powResponse, nil
+		}
+
+		powResponse, err := api.solveChallenge(ctx, challenge)
 		if err != nil {
-			return nil, fmt.Errorf("failed to solve PoW challenge: %w", err)
+			return "", err
 		}
+
+		// 求解期间挑战过期时服务端会拒绝请求，换一个新挑战重试一次
+		if attempt == 1 && challengeExpired(challenge, time.Now()) {
+			api.log.Infof("PoW challenge expired during solve, retrying with a new one")
+			continue
+		}
+
+		api.powCache.put(challenge, powResponse)
+		return powResponse, nil
 	}
+}
 
-	reqBody, err := json.Marshal(jsonData)
+// solveChallenge 使用客户端的求解器求解挑战并记录耗时，所有失败都以 *PoWError 返回
+func (api *DeepSeekAPI) solveChallenge(ctx context.Context, challenge ChallengeConfig) (string, error) {
+	start := time.Now()
+	powResponse, err := api.powSolver.SolveChallenge(ctx, challenge)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return "", err
+	}
+	solveDuration := time.Since(start)
+	// 统计接口使用整数难度，小数难度四舍五入
+	difficulty := int(math.Round(challenge.Difficulty))
+	api.metrics.OnPoWSolve(difficulty, solveDuration)
+	api.powStats.record(difficulty, solveDuration)
+
+	if powResponse == "" {
+		return "", &PoWError{Stage: PoWStageEncode, Err: fmt.Errorf("PoW response is empty")}
 	}
+	return powResponse, nil
+}
 
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+// makeRequest 发送 HTTP 请求，jsonData 为 nil 时不发送请求体（用于 GET 请求）
+func (api *DeepSeekAPI) makeRequest(ctx context.Context, method, endpoint string, jsonData map[string]interface{}, powRequired bool) (map[string]interface{}, error) {
+	ctx, done, err := api.track(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	url := fmt.Sprintf("%s%s", api.baseURL, endpoint)
+	// 查询参数包含会话 ID、游标等，不能出现在指标标签和错误信息中，否则标签数量没有上限，会话 ID 也会泄露到日志
+	endpoint = stripQuery(endpoint)
+
+	var powResponse string
+	if powRequired {
+		var err error
+		powResponse, err = api.solvePow(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var reqBody io.Reader
+	if jsonData != nil {
+		jsonBytes, err := json.Marshal(jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -179,7 +446,7 @@ func (api *DeepSeekAPI) makeRequest(method, endpoint string, jsonData map[string
 		req.Header.Set(k, v)
 	}
 
-	resp, err := api.client.Do(req)
+	resp, err := api.do(req, endpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
@@ -190,20 +457,9 @@ func (api *DeepSeekAPI) makeRequest(method, endpoint string, jsonData map[string
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	if resp.StatusCode == http.StatusUnauthorized {
-		return nil, fmt.Errorf("authentication failed: invalid or expired token")
-	}
-
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("rate limit exceeded")
-	}
-
-	if resp.StatusCode >= http.StatusInternalServerError {
-		return nil, fmt.Errorf("server error: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed: status %d, body: %s", resp.StatusCode, string(body))
+		api.log.Warnf("Request to %s failed: status %d", endpoint, resp.StatusCode)
+		return nil, newAPIError(endpoint, resp, body)
 	}
 
 	var result map[string]interface{}
@@ -216,8 +472,23 @@ func (api *DeepSeekAPI) makeRequest(method, endpoint string, jsonData map[string
 
 // CreateChatSession 创建新的聊天会话
 func (api *DeepSeekAPI) CreateChatSession() (string, error) {
-	resp, err := api.makeRequest("POST", "/chat_session/create", map[string]interface{}{
-		"character_id": nil,
+	return api.CreateChatSessionContext(context.Background())
+}
+
+// CreateChatSessionContext 与 CreateChatSession 相同，但可以通过 ctx 取消请求
+func (api *DeepSeekAPI) CreateChatSessionContext(ctx context.Context) (string, error) {
+	return api.createChatSession(ctx, nil)
+}
+
+// createChatSession 创建会话，characterID 为 nil 时创建普通会话
+func (api *DeepSeekAPI) createChatSession(ctx context.Context, characterID interface{}) (string, error) {
+	ctx, err := api.pinToken(ctx, "")
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := api.makeRequest(ctx, "POST", "/chat_session/create", map[string]interface{}{
+		"character_id": characterID,
 	}, false)
 	if err != nil {
 		return "", err
@@ -238,56 +509,313 @@ func (api *DeepSeekAPI) CreateChatSession() (string, error) {
 		return "", fmt.Errorf("invalid response format: missing id")
 	}
 
+	api.rememberSessionToken(ctx, id)
 	return id, nil
 }
 
+// 实际观察到的 Chunk.FinishReason 取值，只出现在流的最后一个数据块上
+// 回答被内容审核拦截时不会以 finish_reason 结束，而是返回 ErrContentFiltered
+const (
+	// FinishReasonStop 回答自然结束
+	FinishReasonStop = "stop"
+	// FinishReasonLength 回答达到长度上限被截断，可以以该回答为父消息发送“继续”接着生成
+	FinishReasonLength = "length"
+	// FinishReasonEOF 不是服务端返回的值：连接在收到 [DONE] 或 finish_reason 之前正常关闭时，
+	// 客户端补发的最后一个数据块使用该值，此时回答可能不完整
+	FinishReasonEOF = "eof"
+)
+
 // Chunk 表示流式响应的一个数据块
+// 有思考过程时，第一个回答内容之前会先发送一个没有内容的 answer_start 数据块，表示思考结束、开始输出回答
 type Chunk struct {
-	Type         string `json:"type"`          // "text" 或 "thinking"
-	Content      string `json:"content"`       // 内容
-	MessageID    string `json:"message_id"`    // 消息 ID（如果有）
-	FinishReason string `json:"finish_reason"` // 完成原因（如果有）
+	Type         string `json:"type,omitempty"`          // "text"、"thinking"、"meta"（见 ChatSessionID 和 RequestMessageID）、"answer_start" 或 "restart"（见 WithStreamReconnect）
+	Content      string `json:"content,omitempty"`       // 内容
+	MessageID    string `json:"message_id,omitempty"`    // 消息 ID（如果有）
+	FinishReason string `json:"finish_reason,omitempty"` // 完成原因，见 FinishReasonStop 等常量，不为空即表示生成结束
+
+	// ReceivedAt 解析到该数据块所在 SSE 行的时间，可用于计算首字延迟和字间延迟；为零值时序列化会省略，见 MarshalJSON
+	ReceivedAt time.Time `json:"received_at"`
+	// Index 数据块在本次请求中的序号，从 0 开始，每发送一个数据块加 1
+	Index int `json:"index,omitempty"`
+
+	// Event 和 EventID 为数据块所在 SSE 事件的 event: 和 id: 字段，服务端没有发送时为空
+	// EventID 按 SSE 规范在后续事件中保持，直到服务端发送新的 id:
+	Event   string `json:"event,omitempty"`
+	EventID string `json:"event_id,omitempty"`
+
+	// ChatSessionID 只在 Type 为 "meta" 的数据块中设置，为 WithAutoSession 自动创建的会话 ID
+	ChatSessionID string `json:"chat_session_id,omitempty"`
+	// RequestMessageID 只在 Type 为 "meta" 的数据块中设置，为服务端分配给这一轮用户消息的 ID，
+	// 同一数据块的 MessageID 为回答的 ID；可以传给 EditMessage 修改这条消息
+	RequestMessageID string `json:"request_message_id,omitempty"`
+
+	// Usage 本次回答消耗的 token 数，只在流的最后一个数据块上设置，服务端没有返回用量时为 nil
+	Usage *TokenUsage `json:"usage,omitempty"`
+	// Timings 思考和回答阶段的时间，与 Usage 一样只在流的最后一个数据块上设置，没有任何内容时为 nil
+	Timings *Timings `json:"timings,omitempty"`
+}
+
+// MarshalJSON 与默认的序列化相同，但省略零值的 ReceivedAt：omitempty 对 time.Time 这样的结构体不生效
+// 反序列化不需要特殊处理，缺少的字段保持零值
+func (c Chunk) MarshalJSON() ([]byte, error) {
+	type chunk Chunk // 没有 MarshalJSON 方法，避免递归
+	aux := struct {
+		chunk
+		ReceivedAt *time.Time `json:"received_at,omitempty"`
+	}{chunk: chunk(c)}
+	if !c.ReceivedAt.IsZero() {
+		aux.ReceivedAt = &c.ReceivedAt
+	}
+	return json.Marshal(aux)
+}
+
+// String 返回一行简短的摘要，例如 "#3 text len=12 finish=stop"，用于日志；不包含内容本身
+func (c Chunk) String() string {
+	typ := c.Type
+	if typ == "" {
+		typ = "-"
+	}
+	s := fmt.Sprintf("#%d %s len=%d", c.Index, typ, len(c.Content))
+	if c.FinishReason != "" {
+		s += " finish=" + c.FinishReason
+	}
+	return s
+}
+
+// TokenUsage 一次回答的 token 用量，服务端只返回总数时 PromptTokens 和 CompletionTokens 为 0
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
 }
 
 // ChatCompletion 发送消息并获取流式响应
 func (api *DeepSeekAPI) ChatCompletion(chatSessionID, prompt string, parentMessageID *string, thinkingEnabled, searchEnabled bool) (<-chan Chunk, <-chan error) {
+	return api.ChatCompletionContext(context.Background(), chatSessionID, prompt, parentMessageID, thinkingEnabled, searchEnabled)
+}
+
+// ChatCompletionContext 与 ChatCompletion 相同，但可以通过 ctx 取消请求
+// ctx 取消后流会尽快结束，errChan 中返回 ctx.Err()，取消前已经收到的数据块仍会先从 chunkChan 读出
+func (api *DeepSeekAPI) ChatCompletionContext(ctx context.Context, chatSessionID, prompt string, parentMessageID *string, thinkingEnabled, searchEnabled bool) (<-chan Chunk, <-chan error) {
+	// 参数是明确的设置，不使用客户端默认值
+	opts := CompletionOptions{
+		ThinkingEnabled: thinkingEnabled,
+		SearchEnabled:   searchEnabled,
+		thinkingSet:     true,
+		searchSet:       true,
+	}
+	if parentMessageID != nil {
+		opts.ParentMessageID = *parentMessageID
+	}
+	return api.Complete(ctx, chatSessionID, prompt, opts)
+}
+
+// Complete 发送消息并获取流式响应，请求参数通过 WithThinking、WithParent 等选项
+// 或 CompletionOptions 结构体指定，按顺序生效
+// ctx 取消后流会尽快结束，errChan 中返回 ctx.Err()，取消前已经收到的数据块仍会先从 chunkChan 读出
+func (api *DeepSeekAPI) Complete(ctx context.Context, chatSessionID, prompt string, options ...CompletionOption) (<-chan Chunk, <-chan error) {
+	return api.stream(ctx, completionEndpoint, chatSessionID, prompt, "", newCompletionOptions(options))
+}
+
+// EditMessage 修改会话中已发送的用户消息并重新生成回答，与网页版的“编辑”相同，
+// 会在 messageID 的父消息下形成新的对话分支，原来的分支仍保留在服务端
+// 新的用户消息和回答的 ID 通过 Type 为 "meta" 的数据块返回（RequestMessageID 和 MessageID）
+// opts 中的 WithParent 和系统指令对编辑请求无效
+func (api *DeepSeekAPI) EditMessage(ctx context.Context, chatSessionID, messageID, newPrompt string, options ...CompletionOption) (<-chan Chunk, <-chan error) {
+	opts := newCompletionOptions(options)
+	if chatSessionID == "" || messageID == "" {
+		return failedStream(opts, fmt.Errorf("chat session id and message id are required"))
+	}
+	return api.stream(ctx, editMessageEndpoint, chatSessionID, newPrompt, messageID, opts)
+}
+
+// RegenerateMessage 重新生成会话中的一条回答，与网页版的“重新生成”相同，新回答与原回答互为兄弟消息
+// messageID 为要重新生成的回答的消息 ID；重新生成使用 opts 中的 WithThinking、WithSearch 等设置，
+// 与原回答的设置无关，例如可以开启深度思考重新回答。新回答的 ID 通过 Type 为 "meta" 的数据块返回
+func (api *DeepSeekAPI) RegenerateMessage(ctx context.Context, chatSessionID, messageID string, options ...CompletionOption) (<-chan Chunk, <-chan error) {
+	opts := newCompletionOptions(options)
+	if chatSessionID == "" || messageID == "" {
+		return failedStream(opts, fmt.Errorf("chat session id and message id are required"))
+	}
+	return api.stream(ctx, regenerateEndpoint, chatSessionID, "", messageID, opts)
+}
+
+// failedStream 返回只包含 err 的流，并关闭 opts.RawEvents
+func failedStream(opts CompletionOptions, err error) (<-chan Chunk, <-chan error) {
+	if opts.RawEvents != nil {
+		close(opts.RawEvents)
+	}
+	chunkChan := make(chan Chunk)
+	errChan := make(chan error, 1)
+	close(chunkChan)
+	errChan <- err
+	close(errChan)
+	return chunkChan, errChan
+}
+
+// 流式对话接口的路径
+const (
+	completionEndpoint  = "/chat/completion"
+	editMessageEndpoint = "/chat/edit_message"
+	regenerateEndpoint  = "/chat/regenerate"
+)
+
+// stream 向 endpoint 发送对话请求并解析流式响应，Complete、EditMessage 和 RegenerateMessage 共用
+// messageID 不为空时表示编辑或重新生成该消息，此时不发送父消息和系统指令；重新生成时不发送 prompt
+func (api *DeepSeekAPI) stream(ctx context.Context, endpoint, chatSessionID, prompt, messageID string, opts CompletionOptions) (<-chan Chunk, <-chan error) {
+	api.applyDefaults(&opts)
+
+	// 在启动 goroutine 之前登记，保证 Close 一定会等待这次请求
+	ctx, done, err := api.track(ctx)
+	if err == nil {
+		// PoW 挑战和对话请求必须使用同一个 token
+		if ctx, err = api.pinToken(ctx, chatSessionID); err != nil {
+			done()
+		}
+	}
+	if err != nil {
+		return failedStream(opts, err)
+	}
+
+	// WithCompletionTimeout 限制整个请求（包括 PoW）的总时长，超时后以 ErrCompletionTimeout 为原因取消
+	cancelTimeout := context.CancelFunc(func() {})
+	if api.completionTimeout > 0 {
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, api.completionTimeout, ErrCompletionTimeout)
+	}
+
 	chunkChan := make(chan Chunk, 10)
 	errChan := make(chan error, 1)
 
 	go func() {
+		defer done()
+		defer cancelTimeout()
 		defer close(chunkChan)
 		defer close(errChan)
+		if opts.RawEvents != nil {
+			defer close(opts.RawEvents)
+		}
+
+		// timeoutErr 超过 WithCompletionTimeout 时把 ctx 导致的错误替换为 ErrCompletionTimeout，其他错误原样返回
+		timeoutErr := func(err error) error {
+			if errors.Is(context.Cause(ctx), ErrCompletionTimeout) {
+				return fmt.Errorf("%w after %s: %w", ErrCompletionTimeout, api.completionTimeout, context.DeadlineExceeded)
+			}
+			return err
+		}
+
+		index := 0
+		var timings Timings
+		start := time.Now()
+		// stats 本次请求的流统计，WithStreamStats 要求时在通道关闭前写回
+		var stats StreamStats
+		if opts.StreamStats != nil {
+			defer func() {
+				stats.Chunks = index
+				stats.Duration = time.Since(start)
+				*opts.StreamStats = stats
+			}()
+		}
+		// sawThinking 和 answerStarted 用于在思考结束、第一个回答内容之前插入 answer_start 数据块
+		sawThinking, answerStarted := false, false
+		// emit 按顺序编号并发送 chunk，调用方不再读取且 ctx 已取消时返回 false
+		// 结束的数据块（带 FinishReason 或 Usage）会附带各阶段的时间
+		var emit func(chunk Chunk) bool
+		emit = func(chunk Chunk) bool {
+			if chunk.Content != "" {
+				switch {
+				case chunk.Type == "thinking":
+					sawThinking = true
+				case chunk.Type != "meta" && sawThinking && !answerStarted:
+					answerStarted = true
+					if !emit(Chunk{Type: "answer_start", ReceivedAt: chunk.ReceivedAt, Event: chunk.Event, EventID: chunk.EventID}) {
+						return false
+					}
+				}
+			}
+			chunk.Index = index
+			timings.record(chunk)
+			if (chunk.FinishReason != "" || chunk.Usage != nil) && !timings.empty() {
+				t := timings
+				chunk.Timings = &t
+			}
+			// 通道有空位时优先发送，ctx 取消前已经收到的内容不会因为 select 的随机选择而丢失
+			select {
+			case chunkChan <- chunk:
+				index++
+				api.metrics.OnChunk()
+				return true
+			default:
+			}
+			select {
+			case chunkChan <- chunk:
+				index++
+				api.metrics.OnChunk()
+				return true
+			case <-ctx.Done():
+				errChan <- timeoutErr(ctx.Err())
+				return false
+			}
+		}
+
+		// 开启 WithAutoSession 时为空的会话 ID 创建新会话，并通过 meta 数据块告知调用方
+		if chatSessionID == "" && api.autoSession {
+			id, err := api.CreateChatSessionContext(ctx)
+			if err != nil {
+				errChan <- timeoutErr(fmt.Errorf("failed to create chat session: %w", err))
+				return
+			}
+			chatSessionID = id
+			if !emit(Chunk{Type: "meta", ChatSessionID: id}) {
+				return
+			}
+		}
 
 		// 获取 PoW 挑战并解决
-		challenge, err := api.getPowChallenge()
+		powResponse, err := api.solvePow(ctx, endpoint)
 		if err != nil {
-			errChan <- fmt.Errorf("failed to get PoW challenge: %w", err)
+			errChan <- timeoutErr(err)
 			return
 		}
 
-		powResponse, err := api.powSolver.SolveChallenge(challenge)
-		if err != nil {
-			errChan <- fmt.Errorf("failed to solve PoW challenge: %w", err)
-			return
+		if api.maxPromptTokens > 0 {
+			if truncated := TruncatePrompt(prompt, api.maxPromptTokens, api.truncateSide); len(truncated) != len(prompt) {
+				api.log.Debugf("Prompt truncated from %d to %d bytes (max %d tokens)", len(prompt), len(truncated), api.maxPromptTokens)
+				prompt = truncated
+			}
 		}
 
-		// 调试：检查 PoW 响应是否为空
-		if powResponse == "" {
-			errChan <- fmt.Errorf("PoW response is empty")
-			return
+		// 系统指令只随会话的第一条消息发送，不参与裁剪；编辑时无法判断是否为第一条消息，不发送
+		systemPrompt := opts.SystemPrompt
+		if systemPrompt == "" {
+			systemPrompt = api.systemPrompt
+		}
+		if systemPrompt != "" && opts.ParentMessageID == "" && messageID == "" {
+			prompt = BuildPrompt([]Message{
+				{Role: RoleSystem, Content: systemPrompt},
+				{Role: RoleUser, Content: prompt},
+			})
+		}
+
+		refFileIDs := opts.RefFileIDs
+		if refFileIDs == nil {
+			refFileIDs = []string{}
 		}
 
 		// 准备请求体
 		reqBody := map[string]interface{}{
 			"chat_session_id":  chatSessionID,
-			"prompt":           prompt,
-			"ref_file_ids":     []interface{}{},
-			"thinking_enabled": thinkingEnabled,
-			"search_enabled":   searchEnabled,
+			"thinking_enabled": opts.ThinkingEnabled,
+			"search_enabled":   opts.SearchEnabled,
+		}
+		if endpoint != regenerateEndpoint {
+			reqBody["prompt"] = prompt
+			reqBody["ref_file_ids"] = refFileIDs
 		}
 
-		if parentMessageID != nil {
-			reqBody["parent_message_id"] = *parentMessageID
+		if messageID != "" {
+			reqBody["message_id"] = messageID
+		} else if opts.ParentMessageID != "" {
+			reqBody["parent_message_id"] = opts.ParentMessageID
 		}
 
 		jsonData, err := json.Marshal(reqBody)
@@ -296,197 +824,470 @@ func (api *DeepSeekAPI) ChatCompletion(chatSessionID, prompt string, parentMessa
 			return
 		}
 
-		// 创建请求
-		url := fmt.Sprintf("%s/chat/completion", BaseURL)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-		if err != nil {
-			errChan <- fmt.Errorf("failed to create request: %w", err)
-			return
-		}
+		// usage 最近一次收到的用量，随结束的数据块一起发送
+		var usage *TokenUsage
+		// finished 是否收到了 [DONE] 或 finish_reason
+		finished := false
+		// parseErrors 连续解析失败的事件数，成功解析后清零
+		parseErrors := 0
+
+		// handleEvent 处理一个完整的 SSE 事件
+		// 返回 stop 表示流已经结束；返回 ok 为 false 表示已经向 errChan 发送了错误
+		handleEvent := func(ev sseEvent) (stop, ok bool) {
+			data := ev.Data
+			// 检查结束标记
+			if data == "[DONE]" {
+				api.log.Tracef("Received [DONE] marker")
+				finished = true
+				return true, true
+			}
 
-		headers := api.getHeaders(powResponse)
-		for k, v := range headers {
-			req.Header.Set(k, v)
-		}
+			// 内容为空的事件是心跳，不算作解析错误
+			if strings.TrimSpace(data) == "" {
+				api.log.Tracef("Received empty heartbeat event")
+				return false, true
+			}
 
-		// 发送请求
-		debugPrint("Making POST request to: %s", url)
-		debugPrint("Request headers: authorization, content-type, x-ds-pow-response")
-		resp, err := api.client.Do(req)
-		if err != nil {
-			errChan <- fmt.Errorf("failed to make request: %w", err)
-			return
-		}
-		defer resp.Body.Close()
+			// 解析 JSON
+			var event map[string]interface{}
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				api.log.Debugf("Failed to parse JSON: %v, data: %s", err, data[:min(len(data), 100)])
+				// 记录解析错误但继续，连续失败次数超过 WithMaxParseErrors 的上限时结束
+				parseErrors++
+				if api.maxParseErrors > 0 && parseErrors >= api.maxParseErrors {
+					errChan <- fmt.Errorf("%w: %d consecutive events, last: %s", ErrTooManyParseErrors, parseErrors, data[:min(len(data), 100)])
+					return false, false
+				}
+				return false, true
+			}
+			parseErrors = 0
+
+			if opts.RawEvents != nil {
+				select {
+				case opts.RawEvents <- json.RawMessage(data):
+				case <-ctx.Done():
+					errChan <- timeoutErr(ctx.Err())
+					return false, false
+				}
+			}
 
-		debugPrint("Response status: %d", resp.StatusCode)
-		debugPrint("Content-Type: %s", resp.Header.Get("Content-Type"))
+			api.log.Tracef("Parsed event: has choices=%v, has v=%v", event["choices"] != nil, event["v"] != nil)
 
-		if resp.StatusCode != http.StatusOK {
-			body, _ := io.ReadAll(resp.Body)
-			bodyStr := string(body)
-			if len(bodyStr) > 500 {
-				bodyStr = bodyStr[:500] + "..."
+			// 服务端在开始生成前告知这一轮用户消息和回答的 ID
+			if requestID, responseID := getID(event, "request_message_id"), getID(event, "response_message_id"); requestID != "" || responseID != "" {
+				chunk := Chunk{
+					Type:             "meta",
+					MessageID:        responseID,
+					RequestMessageID: requestID,
+					ReceivedAt:       ev.ReceivedAt,
+					Event:            ev.Name,
+					EventID:          ev.ID,
+				}
+				return false, emit(chunk)
 			}
-			if resp.StatusCode == http.StatusUnauthorized {
-				errChan <- fmt.Errorf("authentication failed: invalid or expired token")
-			} else if resp.StatusCode == http.StatusTooManyRequests {
-				errChan <- fmt.Errorf("rate limit exceeded")
-			} else {
-				errChan <- fmt.Errorf("request failed: status %d, body: %s", resp.StatusCode, bodyStr)
+
+			// 内容审核拦截以状态事件 {"p":"response/status","v":"CONTENT_FILTER"}
+			// 或 finish_reason 为 content_filter 的形式出现，拦截后结束流
+			if reason := contentFilterReason(event); reason != "" {
+				api.log.Warnf("Completion was blocked by content filter: %s", reason)
+				errChan <- &ContentFilterError{Reason: reason}
+				return false, false
 			}
-			return
-		}
 
-		// 调试：检查响应内容类型
-		contentType := resp.Header.Get("Content-Type")
-		if !strings.Contains(contentType, "text/event-stream") && !strings.Contains(contentType, "text/plain") {
-			// 如果不是 SSE 格式，读取前几百字节看看是什么
-			peekBody := make([]byte, 500)
-			n, _ := resp.Body.Read(peekBody)
-			if n > 0 {
-				errChan <- fmt.Errorf("unexpected content type: %s, first bytes: %s", contentType, string(peekBody[:n]))
-				return
+			if u := parseTokenUsage(event); u != nil {
+				usage = u
 			}
-		}
 
-		// 解析 SSE 流
-		// SSE 格式：每行以 "data: " 开头，可能包含空行
-		reader := bufio.NewReader(resp.Body)
-		lineCount := 0
-		dataLineCount := 0
+			// 状态事件（如 {"p":"response/status","v":"FINISHED"}）不是回答内容
+			if getString(event, "p") == "response/status" {
+				return false, true
+			}
 
-		debugPrint("Starting to read SSE stream...")
+			// 检查是否是简化格式 {"v":"content"}
+			if v, ok := event["v"].(string); ok {
+				// 这是简化格式，直接提取内容
+				chunk := Chunk{
+					Content:    v,
+					Type:       "text",
+					ReceivedAt: ev.ReceivedAt,
+					Event:      ev.Name,
+					EventID:    ev.ID,
+				}
+				api.log.Tracef("Sending chunk (simplified format): content_len=%d", len(chunk.Content))
+				return false, emit(chunk)
+			}
 
-		for {
-			line, err := reader.ReadString('\n')
-			if err != nil {
-				if err == io.EOF {
-					if lineCount == 0 {
-						errChan <- fmt.Errorf("no data received from stream")
+			// 标准格式：解析 choices
+			choices, ok := event["choices"].([]interface{})
+			if !ok || len(choices) == 0 {
+				// 检查是否有 finish_reason 或其他字段
+				if finishReason := getString(event, "finish_reason"); finishReason != "" {
+					chunk := Chunk{
+						FinishReason: finishReason,
+						ReceivedAt:   ev.ReceivedAt,
+						Event:        ev.Name,
+						EventID:      ev.ID,
+						Usage:        usage,
 					}
-					break
+					usage = nil
+					if !emit(chunk) {
+						return false, false
+					}
+					api.log.Debugf("Received finish signal: %s", finishReason)
+					finished = true
+					return true, true
 				}
-				errChan <- fmt.Errorf("failed to read stream: %w", err)
-				return
+				return false, true
 			}
 
-			lineCount++
-			// 去除换行符
-			line = strings.TrimRight(line, "\r\n")
+			choice, ok := choices[0].(map[string]interface{})
+			if !ok {
+				return false, true
+			}
 
-			// 跳过空行
-			if line == "" {
-				continue
+			// 检查是否有 delta
+			delta, ok := choice["delta"].(map[string]interface{})
+			if !ok {
+				// 可能没有 delta，检查是否有其他字段
+				return false, true
 			}
 
-			// 检查是否是 data 行
-			if strings.HasPrefix(line, "data: ") {
-				dataLineCount++
-				data := strings.TrimPrefix(line, "data: ")
+			chunk := Chunk{
+				Content:      getString(delta, "content"),
+				Type:         getString(delta, "type"),
+				FinishReason: getString(choice, "finish_reason"),
+				ReceivedAt:   ev.ReceivedAt,
+				Event:        ev.Name,
+				EventID:      ev.ID,
+			}
 
-				debugPrint("Received data line %d: %s", dataLineCount, data[:min(len(data), 200)])
+			// 尝试从 choice 或 event 中获取 message_id
+			if messageID, ok := choice["message_id"].(string); ok {
+				chunk.MessageID = messageID
+			} else if messageID, ok := event["message_id"].(string); ok {
+				chunk.MessageID = messageID
+			}
 
-				// 检查结束标记
-				if data == "[DONE]" {
-					debugPrint("Received [DONE] marker")
-					break
-				}
+			if chunk.FinishReason != "" {
+				chunk.Usage = usage
+				usage = nil
+			}
 
-				// 解析 JSON
-				var event map[string]interface{}
-				if err := json.Unmarshal([]byte(data), &event); err != nil {
-					debugPrint("Failed to parse JSON: %v, data: %s", err, data[:min(len(data), 100)])
-					// 记录解析错误但继续
-					continue
-				}
+			// 发送 chunk（即使内容为空，也可能有 finish_reason）
+			api.log.Tracef("Sending chunk: type=%s, content_len=%d, finish_reason=%s",
+				chunk.Type, len(chunk.Content), chunk.FinishReason)
+			if !emit(chunk) {
+				return false, false
+			}
 
-				debugPrint("Parsed event: has choices=%v, has v=%v", event["choices"] != nil, event["v"] != nil)
+			// 任何 finish_reason 都表示生成结束，同一个数据块中可能仍然带有内容
+			if chunk.FinishReason != "" {
+				api.log.Debugf("Received finish signal: %s", chunk.FinishReason)
+				finished = true
+				return true, true
+			}
+			return false, true
+		}
 
-				// 检查是否是简化格式 {"v":"content"}
-				if v, ok := event["v"].(string); ok {
-					// 这是简化格式，直接提取内容
-					chunk := Chunk{
-						Content: v,
-						Type:    "text",
+		// seenIDs 已经收到的事件 ID，重连后用来判断服务端是恢复了流还是重新生成了回答
+		seenIDs := make(map[string]bool)
+
+		// restart 服务端忽略 Last-Event-ID、重新生成回答时发送 restart 数据块，并清空上一次连接的状态
+		restart := func(ev sseEvent) bool {
+			api.log.Warnf("Server ignored Last-Event-ID, restarting the answer from the beginning")
+			usage = nil
+			timings = Timings{}
+			sawThinking, answerStarted = false, false
+			return emit(Chunk{Type: "restart", ReceivedAt: ev.ReceivedAt, Event: ev.Name, EventID: ev.ID})
+		}
+
+		// readStream 发送一次请求并解析响应，lastEventID 不为空时通过 Last-Event-ID 请求恢复
+		// resuming 表示这是重连或 WithLastEventID 恢复的请求，需要判断服务端是否恢复了流
+		// 连接中断时返回最后的事件 ID 和读取错误；正常结束时 err 为 nil；ok 为 false 表示已经向 errChan 发送了错误
+		readStream := func(powResponse, lastEventID string, resuming bool) (lastID string, err error, ok bool) {
+			// 流式请求使用可以附带原因取消的 ctx，空闲超时时以 ErrStreamIdle 取消
+			streamCtx, cancelStream := context.WithCancelCause(ctx)
+			defer cancelStream(nil)
+
+			// 创建请求
+			url := api.baseURL + endpoint
+			req, err := http.NewRequestWithContext(streamCtx, "POST", url, bytes.NewBuffer(jsonData))
+			if err != nil {
+				errChan <- fmt.Errorf("failed to create request: %w", err)
+				return "", nil, false
+			}
+
+			headers := api.getHeaders(powResponse)
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			if lastEventID != "" {
+				req.Header.Set("Last-Event-ID", lastEventID)
+			}
+			for k, v := range opts.Headers {
+				req.Header.Set(k, v)
+			}
+
+			// 发送请求
+			api.log.Debugf("Making POST request to: %s", url)
+			api.log.Debugf("Request headers: authorization, content-type, x-ds-pow-response")
+			resp, err := api.do(req, endpoint)
+			if err != nil {
+				errChan <- timeoutErr(fmt.Errorf("failed to make request: %w", err))
+				return "", nil, false
+			}
+			defer resp.Body.Close()
+
+			api.log.Debugf("Response status: %d", resp.StatusCode)
+			api.log.Debugf("Content-Type: %s", resp.Header.Get("Content-Type"))
+
+			if resp.StatusCode != http.StatusOK {
+				body, _ := io.ReadAll(resp.Body)
+				api.log.Warnf("Request to %s failed: status %d", endpoint, resp.StatusCode)
+				errChan <- newAPIError(endpoint, resp, body)
+				return "", nil, false
+			}
+
+			var body io.Reader = resp.Body
+			if api.streamIdleTimeout > 0 {
+				idle := newIdleTimeoutReader(resp.Body, api.streamIdleTimeout, func() {
+					cancelStream(ErrStreamIdle)
+				})
+				defer idle.Stop()
+				body = idle
+			}
+			body = &countingReader{r: body, n: &stats.Bytes}
+			reader := bufio.NewReader(body)
+
+			// 响应类型不是 SSE 时查看开头的内容，看起来像 SSE 就照常解析，否则报告错误
+			// Peek 不会消耗数据，解析器仍然从第一个字节开始读取
+			contentType := resp.Header.Get("Content-Type")
+			if !strings.Contains(contentType, "text/event-stream") && !strings.Contains(contentType, "text/plain") {
+				if peek := peekBuffered(reader, 500); len(peek) > 0 && !looksLikeSSE(peek) {
+					errChan <- fmt.Errorf("unexpected content type: %s, first bytes: %s", contentType, string(peek))
+					return "", nil, false
+				}
+			}
+
+			// 解析 SSE 流
+			// SSE 格式：每行以 "data:" 开头（冒号后的一个空格可选），一个事件可以包含多行 data，
+			// 多行内容以换行符拼接，事件之间以空行分隔
+			var dataLines []string
+			// 按 SSE 规范，恢复的流在服务端发送新的 id 之前沿用 Last-Event-ID
+			event := sseEvent{ID: lastEventID}
+			// idReceived 这次连接是否收到过 id: 字段，重连后用来判断服务端是否恢复了流
+			idReceived := false
+
+			api.log.Debugf("Starting to read SSE stream...")
+
+			for {
+				line, err := readLine(reader, api.maxLineSize)
+				if err != nil && err != io.EOF {
+					if errors.Is(context.Cause(streamCtx), ErrStreamIdle) {
+						errChan <- fmt.Errorf("%w: no data received for %s", ErrStreamIdle, api.streamIdleTimeout)
+						return "", nil, false
 					}
-					debugPrint("Sending chunk (simplified format): content_len=%d", len(chunk.Content))
-					chunkChan <- chunk
-					continue
+					if ctx.Err() != nil {
+						errChan <- timeoutErr(ctx.Err())
+						return "", nil, false
+					}
+					// 连接中断，由调用方决定是否重连
+					return event.ID, err, true
+				}
+				// 空响应的错误在循环结束后统一上报，errChan 只能发送一次
+				eof := err == io.EOF
+				if eof && line == "" && len(dataLines) == 0 {
+					break
+				}
+
+				if line != "" {
+					stats.Lines++
+				}
+				// readLine 会一直累积到真正读到换行符，只有连接在一行中途结束时才会得到不完整的行；
+				// 仍然尝试解析（有些服务端省略最后的换行符），解析失败的内容会被记录后丢弃
+				if eof && line != "" {
+					api.log.Warnf("Stream ended with an unterminated line (%d bytes): %s", len(line), line[:min(len(line), 100)])
+				}
+				// 去除换行符
+				line = strings.TrimRight(line, "\r\n")
+				// 以冒号开头的注释行（例如 ": keep-alive"）是保持连接的心跳，parseSSELine 会忽略它们
+				if strings.HasPrefix(line, ":") {
+					stats.Comments++
+					api.log.Tracef("Received SSE comment: %s", line[:min(len(line), 100)])
 				}
 
-				// 标准格式：解析 choices
-				choices, ok := event["choices"].([]interface{})
-				if !ok || len(choices) == 0 {
-					// 检查是否有 finish_reason 或其他字段
-					if finishReason := getString(event, "finish_reason"); finishReason != "" {
-						chunk := Chunk{
-							FinishReason: finishReason,
+				// 缓冲 data 行，事件的接收时间以第一行为准
+				if field, value, ok := parseSSELine(line); ok {
+					switch field {
+					case "data":
+						if len(dataLines) == 0 {
+							event.ReceivedAt = time.Now()
 						}
-						chunkChan <- chunk
-						if finishReason == "stop" {
-							debugPrint("Received stop signal")
-							break
+						stats.DataLines++
+						dataLines = append(dataLines, value)
+
+						api.log.Tracef("Received data line %d: %s", stats.DataLines, value[:min(len(value), 200)])
+					case "event":
+						event.Name = value
+					case "id":
+						// 按 SSE 规范忽略包含 NUL 的 id
+						if !strings.ContainsRune(value, 0) {
+							event.ID = value
+							idReceived = true
 						}
 					}
-					continue
 				}
 
-				choice, ok := choices[0].(map[string]interface{})
-				if !ok {
-					continue
-				}
+				// 空行表示事件结束，流结束时也要处理最后一个没有以空行结尾的事件
+				if (line == "" || eof) && len(dataLines) > 0 {
+					event.Data = strings.Join(dataLines, "\n")
+					dataLines = dataLines[:0]
+
+					if resuming {
+						resuming = false
+						// 服务端恢复流时从 Last-Event-ID 之后继续发送新的事件 ID；第一个事件没有 ID 或 ID 已经收到过，
+						// 说明服务端忽略了请求头、从头重新生成了回答
+						if !idReceived || seenIDs[event.ID] {
+							if !restart(event) {
+								return "", nil, false
+							}
+						}
+					}
+					if event.ID != "" {
+						seenIDs[event.ID] = true
+					}
 
-				// 检查是否有 delta
-				delta, ok := choice["delta"].(map[string]interface{})
-				if !ok {
-					// 可能没有 delta，检查是否有其他字段
-					continue
+					stop, ok := handleEvent(event)
+					if !ok {
+						return "", nil, false
+					}
+					if stop {
+						break
+					}
+				}
+				// 事件名只对当前事件有效，id 在后续事件中保持
+				if line == "" {
+					event.Name = ""
 				}
 
-				chunk := Chunk{
-					Content:      getString(delta, "content"),
-					Type:         getString(delta, "type"),
-					FinishReason: getString(choice, "finish_reason"),
+				if eof {
+					break
 				}
+			}
+			return event.ID, nil, true
+		}
 
-				// 尝试从 choice 或 event 中获取 message_id
-				if messageID, ok := choice["message_id"].(string); ok {
-					chunk.MessageID = messageID
-				} else if messageID, ok := event["message_id"].(string); ok {
-					chunk.MessageID = messageID
+		lastEventID := opts.LastEventID
+		for attempt := 0; ; attempt++ {
+			lastID, readErr, ok := readStream(powResponse, lastEventID, attempt > 0 || lastEventID != "")
+			if !ok {
+				return
+			}
+			// 已经结束、重连次数用完，或者连接正常关闭但没有任何数据（空响应）时不再重连
+			if finished || attempt >= api.streamReconnects || (readErr == nil && stats.DataLines == 0) {
+				if readErr != nil {
+					errChan <- fmt.Errorf("failed to read stream: %w", readErr)
+					return
 				}
+				break
+			}
 
-				// 发送 chunk（即使内容为空，也可能有 finish_reason）
-				debugPrint("Sending chunk: type=%s, content_len=%d, finish_reason=%s",
-					chunk.Type, len(chunk.Content), chunk.FinishReason)
-				chunkChan <- chunk
+			stats.Reconnects++
+			lastEventID = lastID
+			api.log.Warnf("Stream ended before finishing (%v), reconnecting (%d/%d) with Last-Event-ID %q",
+				readErr, attempt+1, api.streamReconnects, lastEventID)
+			// 每个请求需要新的 PoW；批处理预先求解的答案已经用过，不能复用
+			powResponse, err = api.solvePow(context.WithValue(ctx, preparedPoWKey{}, preparedPoW{}), endpoint)
+			if err != nil {
+				errChan <- timeoutErr(err)
+				return
+			}
+		}
 
-				if chunk.FinishReason == "stop" {
-					debugPrint("Received stop signal")
-					break
-				}
-			} else {
-				// 记录非 data 行（用于调试）
-				// 只在前面几行记录，避免输出太多
-				if lineCount <= 5 {
-					// 可以在这里添加调试日志
-				}
+		// 连接在收到结束信号之前正常关闭时补发一个结束数据块，调用方总能通过 FinishReason 判断流已结束
+		if !finished && stats.DataLines > 0 {
+			api.log.Warnf("Stream ended without [DONE] or finish_reason")
+			if !emit(Chunk{FinishReason: FinishReasonEOF, Usage: usage}) {
+				return
 			}
+			usage = nil
+		}
+
+		// 用量在 [DONE] 之前、finish_reason 之外单独到达时，发送一个只带用量的数据块
+		if usage != nil && !emit(Chunk{Usage: usage}) {
+			return
 		}
 
 		// 如果读取了行但没有解析到任何数据，报告错误
-		debugPrint("Finished reading stream: total_lines=%d, data_lines=%d", lineCount, dataLineCount)
-		if lineCount > 0 && dataLineCount == 0 {
-			errChan <- fmt.Errorf("received %d lines but no valid data lines found", lineCount)
-		} else if lineCount == 0 {
-			errChan <- fmt.Errorf("no data received from stream (empty response)")
+		api.log.Debugf("Finished reading stream: total_lines=%d, data_lines=%d, comments=%d", stats.Lines, stats.DataLines, stats.Comments)
+		if stats.Lines > 0 && stats.DataLines == 0 {
+			errChan <- fmt.Errorf("%w: received %d lines (%d comments) but no valid data lines found", ErrNoData, stats.Lines, stats.Comments)
+		} else if stats.Lines == 0 {
+			errChan <- fmt.Errorf("%w (empty response)", ErrNoData)
 		}
 	}()
 
 	return chunkChan, errChan
 }
 
+// parseTokenUsage 解析事件中的 token 用量，支持 {"usage":{...}} 和
+// {"p":"response/accumulated_token_usage","v":n} 两种格式，没有用量时返回 nil
+func parseTokenUsage(event map[string]interface{}) *TokenUsage {
+	if getString(event, "p") == "response/accumulated_token_usage" {
+		if total, ok := event["v"].(float64); ok {
+			return &TokenUsage{TotalTokens: int(total)}
+		}
+		return nil
+	}
+
+	u, ok := event["usage"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	number := func(key string) int {
+		n, _ := u[key].(float64)
+		return int(n)
+	}
+	usage := &TokenUsage{
+		PromptTokens:     number("prompt_tokens"),
+		CompletionTokens: number("completion_tokens"),
+		TotalTokens:      number("total_tokens"),
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
+
+// contentFilterReason 判断事件是否表示回答被内容审核拦截，返回拦截原因，不是拦截事件时返回空字符串
+func contentFilterReason(event map[string]interface{}) string {
+	if getString(event, "p") == "response/status" {
+		if status := getString(event, "v"); status == "CONTENT_FILTER" || status == "BANNED" {
+			return status
+		}
+	}
+	if reason := getString(event, "finish_reason"); reason == "content_filter" {
+		return reason
+	}
+	if choices, ok := event["choices"].([]interface{}); ok && len(choices) > 0 {
+		if choice, ok := choices[0].(map[string]interface{}); ok && getString(choice, "finish_reason") == "content_filter" {
+			return "content_filter"
+		}
+	}
+	return ""
+}
+
+// getID 从 map 中获取 ID，服务端的消息 ID 可能是数字也可能是字符串
+func getID(m map[string]interface{}, key string) string {
+	switch v := m[key].(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
 // getString 从 map 中安全获取字符串值
 func getString(m map[string]interface{}, key string) string {
 	if v, ok := m[key]; ok {
@@ -0,0 +1,78 @@
+package dsk
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChunkJSON(t *testing.T) {
+	receivedAt := time.Date(2026, 10, 16, 12, 0, 0, 123000000, time.UTC)
+	tests := []struct {
+		name  string
+		chunk Chunk
+		want  string
+	}{
+		{"zero", Chunk{}, `{}`},
+		{"text", Chunk{Type: "text", Content: "hi"}, `{"type":"text","content":"hi"}`},
+		{"first chunk", Chunk{Type: "text", Content: "hi", ReceivedAt: receivedAt},
+			`{"type":"text","content":"hi","received_at":"2026-10-16T12:00:00.123Z"}`},
+		{"finish", Chunk{FinishReason: FinishReasonStop, Index: 3, Usage: &TokenUsage{TotalTokens: 7}},
+			`{"finish_reason":"stop","index":3,"usage":{"prompt_tokens":0,"completion_tokens":0,"total_tokens":7}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.chunk)
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("Marshal = %s, want %s", data, tt.want)
+			}
+
+			var got Chunk
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.chunk) {
+				t.Fatalf("round trip = %+v, want %+v", got, tt.chunk)
+			}
+		})
+	}
+}
+
+func TestWithHTTP2NegotiatesH2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	api := &DeepSeekAPI{client: &http.Client{}}
+	WithHTTP2()(api)
+	if err := api.configureTransport(); err != nil {
+		t.Fatalf("configureTransport: %v", err)
+	}
+	transport, ok := api.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport = %T, want *http.Transport", api.client.Transport)
+	}
+	defer transport.CloseIdleConnections()
+	// 测试服务器使用自签名证书，在 WithHTTP2 配置好的 TLS 设置上信任它
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	transport.TLSClientConfig.RootCAs = pool
+
+	resp, err := api.client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("response used HTTP/%d, want HTTP/2", resp.ProtoMajor)
+	}
+}
@@ -0,0 +1,184 @@
+package dsk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BatchRequest 批量请求中的一个 prompt
+type BatchRequest struct {
+	ChatSessionID   string // 为空时为该请求创建新会话
+	Prompt          string
+	ParentMessageID *string
+	ThinkingEnabled bool
+	SearchEnabled   bool
+}
+
+// BatchResult 批量请求中单个 prompt 的结果，与 BatchRequest 一一对应
+type BatchResult struct {
+	ChatSessionID string // 实际使用的会话 ID
+	Text          string // 拼接后的回答内容
+	Thinking      string // 拼接后的思考过程，请求未开启思考时为空
+	MessageID     string // 回答的消息 ID（如果有）
+	Err           error  // 该请求的错误，不影响其他请求
+}
+
+// BatchComplete 以最多 concurrency 个并发执行多个互相独立的请求，适合评测等批量场景
+// 返回的结果与 reqs 顺序一致，单个请求失败只会记录在对应结果的 Err 中；
+// 只有 ctx 在所有请求完成前被取消时才返回非 nil 的 error，此时未执行的请求的 Err 为 ctx.Err()
+//
+// 请求按每组 concurrency 个预先创建会话并获取 PoW 挑战，一组挑战同时求解，与上一组的回答同时进行；
+// 实际并行求解的数量取决于 WithPoWConcurrency 的实例数。预先求解的结果在发送前过期时重新求解
+func (api *DeepSeekAPI) BatchComplete(ctx context.Context, reqs []BatchRequest, concurrency int) ([]BatchResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]BatchResult, len(reqs))
+	jobs := make(chan batchJob, concurrency)
+	go api.prepareBatch(ctx, reqs, concurrency, jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency && w < len(reqs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				results[job.index] = api.completeOne(job, reqs[job.index])
+			}
+		}()
+	}
+
+	wg.Wait()
+	return results, ctx.Err()
+}
+
+// batchJob 准备好会话和 PoW 的批量请求
+type batchJob struct {
+	index     int
+	ctx       context.Context // 固定了会话 token、可能带有预先求解的 PoW
+	sessionID string
+	challenge *ChallengeConfig // 预先获取的挑战，获取失败时为 nil，发送请求时再按正常流程求解
+	err       error
+}
+
+// preparedPoW 预先求解的 PoW，通过 ctx 传给 solvePow
+type preparedPoW struct {
+	endpoint  string
+	challenge ChallengeConfig
+	response  string
+}
+
+// preparedPoWKey ctx 中预先求解的 PoW
+type preparedPoWKey struct{}
+
+// prepareBatch 按每组 size 个请求准备会话和 PoW，依次发送到 jobs，结束后关闭 jobs
+// jobs 的缓冲区只有一组，准备的进度最多领先执行一组，避免挑战在排队时过期
+func (api *DeepSeekAPI) prepareBatch(ctx context.Context, reqs []BatchRequest, size int, jobs chan<- batchJob) {
+	defer close(jobs)
+
+	for start := 0; start < len(reqs); start += size {
+		wave := make([]batchJob, min(size, len(reqs)-start))
+
+		var wg sync.WaitGroup
+		for i := range wave {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				wave[i] = api.prepareBatchJob(ctx, start+i, reqs[start+i])
+			}(i)
+		}
+		wg.Wait()
+
+		api.solveBatchPoW(ctx, wave)
+		for _, job := range wave {
+			jobs <- job
+		}
+	}
+}
+
+// prepareBatchJob 为单个请求创建会话、固定 token 并获取对话接口的 PoW 挑战
+func (api *DeepSeekAPI) prepareBatchJob(ctx context.Context, index int, req BatchRequest) batchJob {
+	job := batchJob{index: index, ctx: ctx, sessionID: req.ChatSessionID}
+	if err := ctx.Err(); err != nil {
+		job.err = err
+		return job
+	}
+
+	if job.sessionID == "" {
+		id, err := api.CreateChatSessionContext(ctx)
+		if err != nil {
+			job.err = err
+			return job
+		}
+		job.sessionID = id
+	}
+
+	// PoW 挑战与账号绑定，必须使用会话的 token 获取
+	pinned, err := api.pinToken(ctx, job.sessionID)
+	if err != nil {
+		job.err = err
+		return job
+	}
+	job.ctx = pinned
+
+	// 获取失败或即将过期的挑战不预先求解，发送请求时按正常流程处理
+	challenge, err := api.getPowChallenge(pinned, completionEndpoint)
+	if err != nil || validateChallenge(challenge, time.Now().Add(powExpiryMargin)) != nil {
+		return job
+	}
+	job.challenge = &challenge
+	return job
+}
+
+// solveBatchPoW 并发求解一组请求的挑战，把结果放入各自的 ctx；求解失败的请求在发送时重新求解
+func (api *DeepSeekAPI) solveBatchPoW(ctx context.Context, wave []batchJob) {
+	var configs []ChallengeConfig
+	var indexes []int
+	for i, job := range wave {
+		if job.challenge != nil {
+			configs = append(configs, *job.challenge)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(configs) == 0 {
+		return
+	}
+
+	responses, err := solveMany(ctx, powSolverFunc(api.solveChallenge), configs)
+	if err != nil {
+		api.log.Debugf("Batch PoW solve failed, affected requests will solve again: %v", err)
+	}
+	for k, i := range indexes {
+		if responses[k] == "" {
+			continue
+		}
+		wave[i].ctx = context.WithValue(wave[i].ctx, preparedPoWKey{}, preparedPoW{
+			endpoint:  completionEndpoint,
+			challenge: configs[k],
+			response:  responses[k],
+		})
+	}
+}
+
+// powSolverFunc 把函数适配为 PoWSolver
+type powSolverFunc func(ctx context.Context, config ChallengeConfig) (string, error)
+
+func (f powSolverFunc) SolveChallenge(ctx context.Context, config ChallengeConfig) (string, error) {
+	return f(ctx, config)
+}
+
+// completeOne 执行批量请求中的单个请求
+func (api *DeepSeekAPI) completeOne(job batchJob, req BatchRequest) BatchResult {
+	result := BatchResult{ChatSessionID: job.sessionID}
+	if job.err != nil {
+		result.Err = job.err
+		return result
+	}
+
+	chunkChan, errChan := api.ChatCompletionContext(job.ctx, job.sessionID, req.Prompt, req.ParentMessageID, req.ThinkingEnabled, req.SearchEnabled)
+	collected, err := CollectResult(chunkChan, errChan)
+	result.Text, result.Thinking, result.MessageID, result.Err = collected.Text, collected.Thinking, collected.MessageID, err
+	return result
+}
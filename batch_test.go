@@ -0,0 +1,72 @@
+package dsk_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+)
+
+func TestBatchCompleteSolvesPoWOncePerRequest(t *testing.T) {
+	var challenges, completions atomic.Int32
+	handler := dsktest.NewHandler(dsktest.MockOptions{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/chat/create_pow_challenge"):
+			challenges.Add(1)
+		case strings.HasSuffix(r.URL.Path, "/chat/completion"):
+			completions.Add(1)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	solver := &recordingSolver{}
+	api := newTestClient(t, srv, dsk.WithPoWSolver(solver))
+
+	reqs := make([]dsk.BatchRequest, 5)
+	for i := range reqs {
+		reqs[i].Prompt = "hi"
+	}
+	results, err := api.BatchComplete(context.Background(), reqs, 2)
+	if err != nil {
+		t.Fatalf("BatchComplete: %v", err)
+	}
+
+	for i, result := range results {
+		if result.Err != nil || result.Text != "Hello from dsktest" || result.ChatSessionID != dsktest.SessionID {
+			t.Fatalf("result %d = %+v", i, result)
+		}
+	}
+	// 预先求解的 PoW 被对话请求直接使用，不会再获取和求解一次
+	if c, n := challenges.Load(), len(solver.configs); c != 5 || n != 5 {
+		t.Fatalf("fetched %d challenges and solved %d, want 5 each", c, n)
+	}
+	if n := completions.Load(); n != 5 {
+		t.Fatalf("server received %d completions, want 5", n)
+	}
+}
+
+func TestBatchCompleteCanceled(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{})
+	defer srv.Close()
+	api := newTestClient(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, err := api.BatchComplete(ctx, make([]dsk.BatchRequest, 3), 2)
+	if err != context.Canceled {
+		t.Fatalf("BatchComplete error = %v, want context.Canceled", err)
+	}
+	for i, result := range results {
+		if result.Err != context.Canceled {
+			t.Fatalf("result %d Err = %v, want context.Canceled", i, result.Err)
+		}
+	}
+}
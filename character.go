@@ -0,0 +1,76 @@
+package dsk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// Character 可以用于创建会话的角色
+type Character struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// CreateChatSessionWithCharacter 创建使用指定角色的会话，characterID 为角色 ID，可以通过 ListCharacters 获取
+// 与 CreateChatSessionContext 的区别只在于请求中的 character_id，返回的会话 ID 用法相同
+func (api *DeepSeekAPI) CreateChatSessionWithCharacter(ctx context.Context, characterID string) (string, error) {
+	if err := validateCharacterID(characterID); err != nil {
+		return "", err
+	}
+	return api.createChatSession(ctx, characterID)
+}
+
+// validateCharacterID 检查角色 ID 不为空且不包含空白或控制字符，避免把明显错误的值发送给服务端
+func validateCharacterID(id string) error {
+	if id == "" {
+		return fmt.Errorf("character id is required")
+	}
+	if strings.IndexFunc(id, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) >= 0 {
+		return fmt.Errorf("invalid character id: %q", id)
+	}
+	return nil
+}
+
+// ListCharacters 列出当前账号可以使用的角色，返回的 ID 用于 CreateChatSessionWithCharacter
+// 账号没有角色功能的权限（接口返回 403 或 404）或没有任何角色时返回空切片和 nil
+func (api *DeepSeekAPI) ListCharacters(ctx context.Context) ([]Character, error) {
+	resp, err := api.makeRequest(ctx, "GET", "/characters", nil, false)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusForbidden) {
+			return []Character{}, nil
+		}
+		return nil, err
+	}
+
+	// 没有 biz_data 表示没有可用的角色
+	if data, ok := resp["data"].(map[string]interface{}); ok && data["biz_data"] == nil {
+		return []Character{}, nil
+	}
+	// biz_data 可能直接是列表，也可能是 {"characters": [...]}
+	var raw json.RawMessage
+	if err := decodeBizData(resp, &raw); err != nil {
+		return nil, err
+	}
+	var characters []Character
+	if err := json.Unmarshal(raw, &characters); err != nil {
+		var wrapped struct {
+			Characters []Character `json:"characters"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to decode characters: %w", err)
+		}
+		characters = wrapped.Characters
+	}
+
+	if characters == nil {
+		characters = []Character{}
+	}
+	return characters, nil
+}
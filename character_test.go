@@ -0,0 +1,66 @@
+package dsk_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+func TestListCharacters(t *testing.T) {
+	const list = `[{"id":"c1","name":"翻译","description":"中英互译"},{"id":"c2","name":"面试官"}]`
+	want := []dsk.Character{
+		{ID: "c1", Name: "翻译", Description: "中英互译"},
+		{ID: "c2", Name: "面试官"},
+	}
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    []dsk.Character
+		wantErr bool
+	}{
+		{"list", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":` + list + `}}`, want, false},
+		{"wrapped list", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":{"characters":` + list + `}}}`, want, false},
+		{"empty list", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":[]}}`, []dsk.Character{}, false},
+		{"wrapped null", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":{"characters":null}}}`, []dsk.Character{}, false},
+		{"null biz_data", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":null}}`, []dsk.Character{}, false},
+		{"forbidden", http.StatusForbidden, `{"code":40300,"msg":"forbidden"}`, []dsk.Character{}, false},
+		{"not found", http.StatusNotFound, `{"code":40400,"msg":"not found"}`, []dsk.Character{}, false},
+		{"bad request", http.StatusBadRequest, `{"code":40000,"msg":"bad request"}`, nil, true},
+		{"unexpected shape", http.StatusOK, `{"code":0,"data":{"biz_code":0,"biz_data":"c1"}}`, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v0/characters" {
+					http.NotFound(w, r)
+					return
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			got, err := newTestClient(t, srv).ListCharacters(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ListCharacters = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListCharacters: %v", err)
+			}
+			// 没有角色时返回非 nil 的空切片
+			if got == nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ListCharacters = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
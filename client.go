@@ -0,0 +1,36 @@
+package dsk
+
+import (
+	"context"
+	"io"
+)
+
+// APIClient DeepSeekAPI 对外提供的方法集合
+// 封装本库的代码可以依赖该接口而不是 *DeepSeekAPI，以便在单元测试中替换为 mock 实现
+type APIClient interface {
+	CreateChatSession() (string, error)
+	CreateChatSessionContext(ctx context.Context) (string, error)
+	CreateChatSessionWithCharacter(ctx context.Context, characterID string) (string, error)
+	ListCharacters(ctx context.Context) ([]Character, error)
+	ChatCompletion(chatSessionID, prompt string, parentMessageID *string, thinkingEnabled, searchEnabled bool) (<-chan Chunk, <-chan error)
+	ChatCompletionContext(ctx context.Context, chatSessionID, prompt string, parentMessageID *string, thinkingEnabled, searchEnabled bool) (<-chan Chunk, <-chan error)
+	Complete(ctx context.Context, chatSessionID, prompt string, opts ...CompletionOption) (<-chan Chunk, <-chan error)
+	CompleteMessage(ctx context.Context, chatSessionID, prompt string, opts ...CompletionOption) (Message, error)
+	EditMessage(ctx context.Context, chatSessionID, messageID, newPrompt string, opts ...CompletionOption) (<-chan Chunk, <-chan error)
+	RegenerateMessage(ctx context.Context, chatSessionID, messageID string, opts ...CompletionOption) (<-chan Chunk, <-chan error)
+
+	GetUserProfile(ctx context.Context) (UserProfile, error)
+	GetUsage(ctx context.Context) (Usage, error)
+	ValidateToken(ctx context.Context) error
+	Ping(ctx context.Context) error
+	FetchModels(ctx context.Context) ([]Model, error)
+	FetchMessageHistory(ctx context.Context, chatSessionID string) (MessageHistory, error)
+	FetchMessageHistoryPage(ctx context.Context, chatSessionID, cursor string, limit int) (MessagePage, error)
+	UploadFile(ctx context.Context, name string, r io.Reader, opts ...UploadOption) (UploadedFile, error)
+	SendFeedback(ctx context.Context, chatSessionID, messageID string, rating Feedback, comment string) error
+	Search(ctx context.Context, chatSessionID, query string) ([]SearchResult, error)
+
+	Close(ctx context.Context) error
+}
+
+var _ APIClient = (*DeepSeekAPI)(nil)
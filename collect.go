@@ -0,0 +1,92 @@
+package dsk
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// CompletionResult 一次回答的完整结果，回答内容和思考过程分开保存
+type CompletionResult struct {
+	Text         string      // 回答内容，按收到的顺序拼接
+	Thinking     string      // 思考过程，按收到的顺序拼接，未开启思考时为空
+	MessageID    string      // 回答的消息 ID（如果有）
+	FinishReason string      // 完成原因，见 FinishReasonStop 等常量
+	Usage        *TokenUsage // token 用量，服务端没有返回时为 nil
+}
+
+// CollectResult 读取完整的流式响应，分别拼接回答内容和思考过程，适合需要单独保存思考过程的场景
+// 会一直读取到 chunkChan 关闭，然后返回 errChan 中的错误；出错时（包括 ctx 被取消）仍返回已经收到的部分内容
+func CollectResult(chunkChan <-chan Chunk, errChan <-chan error) (CompletionResult, error) {
+	result, _, err := collect(chunkChan, errChan)
+	return result, err
+}
+
+// collect 是 CollectResult 和 CollectMessage 共用的读取循环，同时返回第一个数据块的接收时间
+func collect(chunkChan <-chan Chunk, errChan <-chan error) (CompletionResult, time.Time, error) {
+	var text, thinking strings.Builder
+	var result CompletionResult
+	var createdAt time.Time
+
+	for chunk := range chunkChan {
+		if createdAt.IsZero() {
+			createdAt = chunk.ReceivedAt
+		}
+		if chunk.MessageID != "" {
+			result.MessageID = chunk.MessageID
+		}
+		if chunk.FinishReason != "" {
+			result.FinishReason = chunk.FinishReason
+		}
+		if chunk.Usage != nil {
+			result.Usage = chunk.Usage
+		}
+		switch chunk.Type {
+		case "thinking":
+			thinking.WriteString(chunk.Content)
+		case "restart":
+			// 服务端重新生成了回答，丢弃之前的部分内容
+			text.Reset()
+			thinking.Reset()
+			result.FinishReason, result.Usage = "", nil
+		case "meta", "answer_start":
+		default:
+			text.WriteString(chunk.Content)
+		}
+	}
+
+	result.Text = text.String()
+	result.Thinking = thinking.String()
+	return result, createdAt, <-errChan
+}
+
+// CollectResponse 读取完整的流式响应并返回拼接后的回答内容（不包含思考过程）
+// 会一直读取到 chunkChan 关闭，然后返回 errChan 中的错误
+// 出错时（包括 ctx 被取消）仍返回已经收到的部分内容，调用方可以保留取消前生成的回答
+// 需要同时获取思考过程时使用 CollectResult
+func CollectResponse(chunkChan <-chan Chunk, errChan <-chan error) (string, error) {
+	result, err := CollectResult(chunkChan, errChan)
+	return result.Text, err
+}
+
+// CollectMessage 读取完整的流式响应并组装为一条 assistant 消息，ParentID 需要由调用方填写，
+// 见 CompleteMessage。出错时仍返回已经收到的部分内容
+func CollectMessage(chunkChan <-chan Chunk, errChan <-chan error) (Message, error) {
+	result, createdAt, err := collect(chunkChan, errChan)
+	return Message{
+		Role:         RoleAssistant,
+		Content:      result.Text,
+		Thinking:     result.Thinking,
+		ID:           result.MessageID,
+		FinishReason: result.FinishReason,
+		Usage:        result.Usage,
+		CreatedAt:    createdAt,
+	}, err
+}
+
+// CompleteMessage 发送消息并等待完整的回答，返回的 Message 的 ParentID 为请求中 WithParent 指定的父消息
+func (api *DeepSeekAPI) CompleteMessage(ctx context.Context, chatSessionID, prompt string, opts ...CompletionOption) (Message, error) {
+	msg, err := CollectMessage(api.Complete(ctx, chatSessionID, prompt, opts...))
+	msg.ParentID = newCompletionOptions(opts).ParentMessageID
+	return msg, err
+}
@@ -0,0 +1,112 @@
+package dsk_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+)
+
+func TestCollectResultSeparatesThinking(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{
+			dsktest.ThinkingEvent("first "),
+			dsktest.ThinkingEvent("second "),
+			dsktest.TextEvent("A"),
+			// 交错到达的内容各自保持收到的顺序
+			dsktest.ThinkingEvent("third"),
+			dsktest.TextEvent("B"),
+			dsktest.TextEvent("C"),
+			dsktest.StopEvent(),
+			dsktest.DoneEvent,
+		},
+	})
+	defer srv.Close()
+
+	api := newTestClient(t, srv)
+	result, err := dsk.CollectResult(api.Complete(context.Background(), dsktest.SessionID, "hi", dsk.WithThinking()))
+	if err != nil {
+		t.Fatalf("CollectResult: %v", err)
+	}
+	if result.Thinking != "first second third" {
+		t.Fatalf("Thinking = %q, want %q", result.Thinking, "first second third")
+	}
+	if result.Text != "ABC" {
+		t.Fatalf("Text = %q, want %q", result.Text, "ABC")
+	}
+	if result.FinishReason != dsk.FinishReasonStop {
+		t.Fatalf("FinishReason = %q, want %q", result.FinishReason, dsk.FinishReasonStop)
+	}
+
+	// CollectResponse 只返回回答内容
+	text, err := dsk.CollectResponse(api.Complete(context.Background(), dsktest.SessionID, "hi", dsk.WithThinking()))
+	if err != nil || text != "ABC" {
+		t.Fatalf("CollectResponse = %q, %v; want %q", text, err, "ABC")
+	}
+}
+
+// chunkStream 把 chunks 放入已关闭的通道，模拟一次以 err 结束的流式响应
+func chunkStream(err error, chunks ...dsk.Chunk) (<-chan dsk.Chunk, <-chan error) {
+	chunkChan := make(chan dsk.Chunk, len(chunks))
+	errChan := make(chan error, 1)
+	for _, chunk := range chunks {
+		chunkChan <- chunk
+	}
+	close(chunkChan)
+	errChan <- err
+	close(errChan)
+	return chunkChan, errChan
+}
+
+func TestCollectMessageRestart(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	usage := &dsk.TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}
+	chunks := []dsk.Chunk{
+		{Type: "thinking", Content: "old thought", MessageID: "2", ReceivedAt: start},
+		{Type: "text", Content: "old", FinishReason: dsk.FinishReasonEOF, Usage: &dsk.TokenUsage{TotalTokens: 1}},
+		{Type: "restart", ReceivedAt: start.Add(time.Second)},
+		{Type: "thinking", Content: "new thought"},
+		{Type: "text", Content: "new"},
+		{Type: "text", FinishReason: dsk.FinishReasonStop, Usage: usage},
+	}
+
+	msg, err := dsk.CollectMessage(chunkStream(nil, chunks...))
+	if err != nil {
+		t.Fatalf("CollectMessage: %v", err)
+	}
+	want := dsk.Message{
+		Role:         dsk.RoleAssistant,
+		Content:      "new",
+		Thinking:     "new thought",
+		ID:           "2",
+		FinishReason: dsk.FinishReasonStop,
+		Usage:        usage,
+		CreatedAt:    start,
+	}
+	if !reflect.DeepEqual(msg, want) {
+		t.Fatalf("CollectMessage = %+v, want %+v", msg, want)
+	}
+
+	// CollectResult 与 CollectMessage 使用同一个读取循环
+	result, err := dsk.CollectResult(chunkStream(nil, chunks...))
+	if err != nil {
+		t.Fatalf("CollectResult: %v", err)
+	}
+	if result.Text != msg.Content || result.Thinking != msg.Thinking || result.MessageID != msg.ID ||
+		result.FinishReason != msg.FinishReason || result.Usage != msg.Usage {
+		t.Fatalf("CollectResult = %+v, differs from CollectMessage %+v", result, msg)
+	}
+
+	// 重新生成后没有完成原因和用量时，不保留之前的值
+	msg, err = dsk.CollectMessage(chunkStream(context.Canceled, chunks[:4]...))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("CollectMessage error = %v, want context.Canceled", err)
+	}
+	if msg.Content != "" || msg.Thinking != "new thought" || msg.FinishReason != "" || msg.Usage != nil {
+		t.Fatalf("CollectMessage after restart = %+v", msg)
+	}
+}
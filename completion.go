@@ -0,0 +1,156 @@
+package dsk
+
+import "encoding/json"
+
+// CompletionOptions Complete 的请求参数，零值表示在新会话中发送普通对话
+// CompletionOptions 本身也实现了 CompletionOption，可以直接作为参数传给 Complete
+type CompletionOptions struct {
+	ParentMessageID string   // 父消息 ID，用于在会话中继续对话，为空表示会话的第一条消息
+	ThinkingEnabled bool     // 启用思考过程
+	SearchEnabled   bool     // 启用网络搜索
+	RefFileIDs      []string // 引用的已上传文件 ID
+	SystemPrompt    string   // 系统指令，覆盖 WithSystemPrompt 的设置，只在会话的第一条消息生效
+
+	// LastEventID 断线重连时上一次请求收到的最后一个事件 ID，见 WithLastEventID
+	LastEventID string
+
+	// Headers 本次请求额外设置的请求头，覆盖客户端的默认值，见 WithCallHeader
+	Headers map[string]string
+
+	// thinkingSet 和 searchSet 表示思考和搜索由选项明确设置过，不再使用 WithDefaultThinking 等客户端默认值
+	thinkingSet bool
+	searchSet   bool
+
+	// StreamStats 不为 nil 时，流结束后写入本次请求的统计，见 WithStreamStats
+	StreamStats *StreamStats
+
+	// RawEvents 不为 nil 时，每个解析成功的 SSE data 内容都会原样发送到该通道，见 WithRawEvents
+	RawEvents chan<- json.RawMessage
+}
+
+// CompletionOption Complete 的单次请求选项
+type CompletionOption interface {
+	applyCompletion(*CompletionOptions)
+}
+
+// applyCompletion 用整个结构体替换之前的设置
+func (o CompletionOptions) applyCompletion(opts *CompletionOptions) {
+	*opts = o
+}
+
+// completionOptionFunc 把函数适配为 CompletionOption
+type completionOptionFunc func(*CompletionOptions)
+
+func (f completionOptionFunc) applyCompletion(opts *CompletionOptions) {
+	f(opts)
+}
+
+// WithThinking 启用思考过程
+func WithThinking() CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.ThinkingEnabled = true
+		opts.thinkingSet = true
+	})
+}
+
+// WithoutThinking 为本次请求关闭思考过程，覆盖客户端的 WithDefaultThinking
+func WithoutThinking() CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.ThinkingEnabled = false
+		opts.thinkingSet = true
+	})
+}
+
+// WithSearch 启用网络搜索
+func WithSearch() CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.SearchEnabled = true
+		opts.searchSet = true
+	})
+}
+
+// WithoutSearch 为本次请求关闭网络搜索，覆盖客户端的 WithDefaultSearch
+func WithoutSearch() CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.SearchEnabled = false
+		opts.searchSet = true
+	})
+}
+
+// applyDefaults 为没有明确设置的思考和搜索使用客户端默认值
+// 直接传入的 CompletionOptions 中为 true 的字段总是生效，为 false 的字段视为未设置
+func (api *DeepSeekAPI) applyDefaults(opts *CompletionOptions) {
+	if !opts.thinkingSet && !opts.ThinkingEnabled {
+		opts.ThinkingEnabled = api.defaultThinking
+	}
+	if !opts.searchSet && !opts.SearchEnabled {
+		opts.SearchEnabled = api.defaultSearch
+	}
+}
+
+// WithParent 设置父消息 ID，在会话中继续对话
+func WithParent(messageID string) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.ParentMessageID = messageID
+	})
+}
+
+// WithRefFiles 引用已上传的文件，可以多次使用
+func WithRefFiles(fileIDs ...string) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.RefFileIDs = append(opts.RefFileIDs, fileIDs...)
+	})
+}
+
+// WithCallSystemPrompt 为本次请求设置系统指令，覆盖客户端的 WithSystemPrompt
+func WithCallSystemPrompt(s string) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.SystemPrompt = s
+	})
+}
+
+// WithRawEvents 把每个解析成功的 SSE data 内容原样发送到 ch，与 chunkChan 并行
+// 可以读取本库尚未解析的字段或新增的事件类型；流结束（包括出错）时 ch 会被关闭。
+// 发送是阻塞的，调用方需要持续读取 ch（或使用带缓冲的通道），否则流会暂停
+func WithRawEvents(ch chan<- json.RawMessage) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.RawEvents = ch
+	})
+}
+
+// WithCallHeader 为本次请求设置请求头，覆盖客户端的默认值（例如 referer、user-agent），不影响其他请求
+// 可以多次使用；authorization 由客户端根据 token 设置，不能通过该选项修改。只作用于对话请求本身，
+// 获取 PoW 挑战等辅助请求仍使用客户端的请求头
+func WithCallHeader(key, value string) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		// 复制后再修改，避免改动调用方传入的 CompletionOptions.Headers
+		headers := make(map[string]string, len(opts.Headers)+1)
+		for k, v := range opts.Headers {
+			headers[k] = v
+		}
+		headers[key] = value
+		opts.Headers = headers
+	})
+}
+
+// WithLastEventID 断线后重新请求时，通过 Last-Event-ID 请求头告知服务端上一次收到的最后一个事件，
+// id 为上一次请求最后一个数据块的 Chunk.EventID，为空时不发送
+// 服务端支持时会从该事件之后继续发送，数据块的 EventID 也从 id 继续；服务端忽略该请求头时会重新生成完整的回答，
+// 响应的第一个事件没有 id: 时认为服务端忽略了该请求头，第一个数据块的 Type 为 "restart"，调用方应当丢弃之前的部分回答
+// 需要自动重连时使用 WithStreamReconnect
+func WithLastEventID(id string) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.LastEventID = id
+	})
+}
+
+// newCompletionOptions 按顺序应用选项
+func newCompletionOptions(opts []CompletionOption) CompletionOptions {
+	var o CompletionOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt.applyCompletion(&o)
+		}
+	}
+	return o
+}
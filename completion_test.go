@@ -0,0 +1,957 @@
+package dsk_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+)
+
+// newTestClient 创建连接到 srv 的客户端，跳过 WASM 求解并关闭日志
+func newTestClient(t testing.TB, srv *httptest.Server, opts ...dsk.Option) *dsk.DeepSeekAPI {
+	t.Helper()
+	opts = append([]dsk.Option{dsk.WithPoWSolver(dsktest.StubSolver{}), dsk.WithLogLevel(dsk.LevelOff)}, opts...)
+	api, err := dsktest.NewClient(srv, opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { api.Close(context.Background()) })
+	return api
+}
+
+// drain 读完两个通道，返回所有数据块和错误
+func drain(chunkChan <-chan dsk.Chunk, errChan <-chan error) ([]dsk.Chunk, error) {
+	var chunks []dsk.Chunk
+	for chunk := range chunkChan {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, <-errChan
+}
+
+// answerText 拼接数据块中的回答内容
+func answerText(chunks []dsk.Chunk) string {
+	var b strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Type == "text" {
+			b.WriteString(chunk.Content)
+		}
+	}
+	return b.String()
+}
+
+// complete 向模拟服务器发送一条消息并读完响应
+func complete(t *testing.T, api *dsk.DeepSeekAPI, opts ...dsk.CompletionOption) ([]dsk.Chunk, error) {
+	t.Helper()
+	return drain(api.Complete(context.Background(), dsktest.SessionID, "hi", opts...))
+}
+
+func TestCompleteDataWithoutSpace(t *testing.T) {
+	// SSE 规范中 data: 后的空格是可选的，两种写法可以混用
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		RawStream: "data:" + dsktest.TextEvent("no space, ") + "\n\n" +
+			"data: " + dsktest.TextEvent("with space") + "\n\n" +
+			"data:" + dsktest.StopEvent() + "\n\n" +
+			"data:" + dsktest.DoneEvent + "\n\n",
+	})
+	defer srv.Close()
+
+	chunks, err := complete(t, newTestClient(t, srv))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != "no space, with space" {
+		t.Fatalf("answer = %q, want %q", got, "no space, with space")
+	}
+	if last := chunks[len(chunks)-1]; last.FinishReason != "stop" {
+		t.Fatalf("last chunk FinishReason = %q, want stop", last.FinishReason)
+	}
+}
+
+func TestCompleteMultiLineEvent(t *testing.T) {
+	// 缩进格式的 JSON 会被模拟服务器拆成多行 data:，客户端需要以换行符拼接后再解析
+	multiLine := `{
+  "choices": [
+    {"index": 0, "delta": {"type": "text", "content": "line one\nline two"}}
+  ]
+}`
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{multiLine, dsktest.StopEvent(), dsktest.DoneEvent},
+	})
+	defer srv.Close()
+
+	var stats dsk.StreamStats
+	chunks, err := complete(t, newTestClient(t, srv), dsk.WithStreamStats(&stats))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != "line one\nline two" {
+		t.Fatalf("answer = %q, want %q", got, "line one\nline two")
+	}
+	// 多行事件的每一行加上结束事件的一行，收到 finish_reason 后不再读取 [DONE]
+	if want := strings.Count(multiLine, "\n") + 1 + 1; stats.DataLines != want {
+		t.Fatalf("DataLines = %d, want %d", stats.DataLines, want)
+	}
+}
+
+func TestCompleteLongDataLine(t *testing.T) {
+	// 一个超过 128KB 的 data 行，例如一次性发送的长思考过程
+	long := strings.Repeat("思考", 100<<10)
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{dsktest.TextEvent(long), dsktest.StopEvent(), dsktest.DoneEvent},
+	})
+	defer srv.Close()
+
+	chunks, err := complete(t, newTestClient(t, srv))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != long {
+		t.Fatalf("answer has %d bytes, want %d bytes intact", len(got), len(long))
+	}
+
+	// 设置 WithMaxLineSize 后超长的行返回 ErrLineTooLong
+	_, err = complete(t, newTestClient(t, srv, dsk.WithMaxLineSize(64<<10)))
+	if !errors.Is(err, dsk.ErrLineTooLong) {
+		t.Fatalf("Complete with WithMaxLineSize error = %v, want ErrLineTooLong", err)
+	}
+}
+
+// splitWriter 把每次写入拆成 size 字节的小块并逐块刷新，模拟在一行中途刷新的服务端
+type splitWriter struct {
+	http.ResponseWriter
+	size int
+}
+
+func (w splitWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(w.size, len(p))
+		if _, err := w.ResponseWriter.Write(p[:n]); err != nil {
+			return written, err
+		}
+		w.Flush()
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w splitWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func TestCompleteSplitWrites(t *testing.T) {
+	handler := dsktest.NewHandler(dsktest.MockOptions{
+		// 最后一个事件没有结尾的换行符
+		RawStream: "data: " + dsktest.TextEvent("你好，") + "\n\n" +
+			"data: " + dsktest.TextEvent("世界") + "\n\n" +
+			"data: " + dsktest.StopEvent(),
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(splitWriter{ResponseWriter: w, size: 3}, r)
+	}))
+	defer srv.Close()
+
+	chunks, err := complete(t, newTestClient(t, srv))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != "你好，世界" {
+		t.Fatalf("answer = %q, want %q", got, "你好，世界")
+	}
+	if last := chunks[len(chunks)-1]; last.FinishReason != "stop" {
+		t.Fatalf("last chunk FinishReason = %q, want stop from the unterminated final line", last.FinishReason)
+	}
+}
+
+// compressWriter 按 Content-Encoding 压缩写入的内容
+type compressWriter struct {
+	http.ResponseWriter
+	w io.WriteCloser
+}
+
+func (w compressWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+
+// compressCompletion 压缩对话接口的响应，不管请求是否声明支持，模拟擅自压缩的代理
+func compressCompletion(encoding string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completion") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		var zw io.WriteCloser
+		if encoding == "deflate" {
+			zw = zlib.NewWriter(w)
+		} else {
+			zw = gzip.NewWriter(w)
+		}
+		defer zw.Close()
+		next.ServeHTTP(compressWriter{ResponseWriter: w, w: zw}, r)
+	})
+}
+
+func TestCompleteCompressedStream(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		opts     []dsk.CompletionOption
+	}{
+		// 请求没有设置 Accept-Encoding 时由 net/http 自动解压
+		{"gzip", "gzip", nil},
+		// 手动设置 Accept-Encoding 后 net/http 不再解压，由客户端解压
+		{"gzip with Accept-Encoding", "gzip", []dsk.CompletionOption{dsk.WithCallHeader("Accept-Encoding", "gzip")}},
+		{"deflate", "deflate", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := dsktest.NewHandler(dsktest.MockOptions{
+				Events: []string{dsktest.ThinkingEvent("嗯"), dsktest.TextEvent("compressed"), dsktest.StopEvent(), dsktest.DoneEvent},
+			})
+			srv := httptest.NewServer(compressCompletion(tt.encoding, handler))
+			defer srv.Close()
+
+			chunks, err := complete(t, newTestClient(t, srv), tt.opts...)
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if got := answerText(chunks); got != "compressed" {
+				t.Fatalf("answer = %q, want %q", got, "compressed")
+			}
+		})
+	}
+}
+
+// recordingSolver 记录收到的挑战，返回固定结果
+type recordingSolver struct {
+	mu      sync.Mutex
+	configs []dsk.ChallengeConfig
+}
+
+func (s *recordingSolver) SolveChallenge(ctx context.Context, config dsk.ChallengeConfig) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs = append(s.configs, config)
+	return "dsktest-pow", nil
+}
+
+func TestCompleteRefetchesExpiredChallenge(t *testing.T) {
+	tests := []struct {
+		name     string
+		expireAt time.Time
+	}{
+		{"expired", time.Now().Add(-time.Minute)},
+		// 剩余有效期太短，求解完成前就会过期
+		{"expiring soon", time.Now().Add(time.Second)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			handler := dsktest.NewHandler(dsktest.MockOptions{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/chat/create_pow_challenge") || fetches.Add(1) > 1 {
+					handler.ServeHTTP(w, r)
+					return
+				}
+				// 第一次下发即将过期的挑战，之后交给模拟服务器
+				challenge := dsktest.Challenge
+				challenge.ExpireAt = tt.expireAt.UnixMilli()
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"code": 0,
+					"data": map[string]interface{}{"biz_data": map[string]interface{}{"challenge": challenge}},
+				})
+			}))
+			defer srv.Close()
+
+			solver := &recordingSolver{}
+			chunks, err := complete(t, newTestClient(t, srv, dsk.WithPoWSolver(solver)))
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if answerText(chunks) == "" {
+				t.Fatal("no answer received")
+			}
+			if n := fetches.Load(); n != 2 {
+				t.Fatalf("fetched %d challenges, want 2", n)
+			}
+			if len(solver.configs) != 1 || solver.configs[0].ExpireAt != dsktest.Challenge.ExpireAt {
+				t.Fatalf("solver received %+v, want only the fresh challenge", solver.configs)
+			}
+		})
+	}
+}
+
+func TestCompleteContentFiltered(t *testing.T) {
+	tests := []struct {
+		name       string
+		event      string
+		wantReason string
+	}{
+		{"status event", dsktest.ContentFilterEvent(), "CONTENT_FILTER"},
+		{"banned status", `{"p":"response/status","o":"SET","v":"BANNED"}`, "BANNED"},
+		{"finish_reason", `{"choices":[{"index":0,"delta":{"type":"text","content":""},"finish_reason":"content_filter"}]}`, "content_filter"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := dsktest.NewMockServer(dsktest.MockOptions{
+				Events: []string{dsktest.TextEvent("partial"), tt.event, dsktest.TextEvent("after filter"), dsktest.DoneEvent},
+			})
+			defer srv.Close()
+
+			chunks, err := complete(t, newTestClient(t, srv))
+			if !errors.Is(err, dsk.ErrContentFiltered) {
+				t.Fatalf("Complete error = %v, want ErrContentFiltered", err)
+			}
+			var filterErr *dsk.ContentFilterError
+			if !errors.As(err, &filterErr) || filterErr.Reason != tt.wantReason {
+				t.Fatalf("Complete error = %#v, want ContentFilterError with reason %q", err, tt.wantReason)
+			}
+			// 拦截前的内容仍然有效，拦截后流结束
+			if got := answerText(chunks); got != "partial" {
+				t.Fatalf("answer = %q, want %q", got, "partial")
+			}
+		})
+	}
+}
+
+func TestCompleteEndsWithoutFinishReason(t *testing.T) {
+	// 连接在发送 finish_reason 和 [DONE] 之前正常关闭
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{dsktest.TextEvent("partial")},
+	})
+	defer srv.Close()
+
+	chunks, err := complete(t, newTestClient(t, srv))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != "partial" {
+		t.Fatalf("answer = %q, want %q", got, "partial")
+	}
+	if last := chunks[len(chunks)-1]; last.FinishReason != dsk.FinishReasonEOF {
+		t.Fatalf("last chunk FinishReason = %q, want %q", last.FinishReason, dsk.FinishReasonEOF)
+	}
+}
+
+func TestCompleteContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		rawStream   string
+		wantErr     bool
+	}{
+		{"text/plain", "text/plain; charset=utf-8", "", false},
+		// 类型不对但内容是 SSE 时照常解析，判断类型时查看的内容不会丢失
+		{"octet-stream with SSE", "application/octet-stream", "", false},
+		{"html error page", "text/html", "<html><body>502 Bad Gateway</body></html>", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := dsktest.NewMockServer(dsktest.MockOptions{ContentType: tt.contentType, RawStream: tt.rawStream})
+			defer srv.Close()
+
+			chunks, err := complete(t, newTestClient(t, srv))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unexpected content type") {
+					t.Fatalf("Complete error = %v, want unexpected content type", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if got := answerText(chunks); got != "Hello from dsktest" {
+				t.Fatalf("answer = %q, want %q", got, "Hello from dsktest")
+			}
+		})
+	}
+}
+
+func TestCompleteConcurrent(t *testing.T) {
+	// 使用真实的 WASM 求解器，检查并发求解时每个请求的 PoW 答案都正确；配合 go test -race 运行
+	var badPoW atomic.Int32
+	handler := dsktest.NewHandler(dsktest.MockOptions{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/chat/completion") {
+			var pow struct {
+				Answer int64 `json:"answer"`
+			}
+			raw, _ := base64.StdEncoding.DecodeString(r.Header.Get("x-ds-pow-response"))
+			if json.Unmarshal(raw, &pow) != nil || pow.Answer != 4242 {
+				badPoW.Add(1)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	api, err := dsktest.NewClient(srv, dsk.WithLogLevel(dsk.LevelOff), dsk.WithPoWConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close(context.Background())
+
+	const workers, perWorker = 6, 2
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				chunks, err := drain(api.Complete(context.Background(), dsktest.SessionID, "hi", dsk.WithThinking()))
+				if err == nil && answerText(chunks) != "Hello from dsktest" {
+					err = errors.New("unexpected answer: " + answerText(chunks))
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := badPoW.Load(); n != 0 {
+		t.Fatalf("%d requests carried a wrong PoW answer", n)
+	}
+}
+
+// capturedRequest 模拟服务器收到的一个请求
+type capturedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// requestRecorder 记录经过的请求后交给 next 处理，请求体为 JSON 时解析到 Body
+type requestRecorder struct {
+	next http.Handler
+
+	mu       sync.Mutex
+	requests []capturedRequest
+}
+
+func (rr *requestRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(strings.NewReader(string(raw)))
+	var body map[string]interface{}
+	json.Unmarshal(raw, &body)
+
+	rr.mu.Lock()
+	rr.requests = append(rr.requests, capturedRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+	rr.mu.Unlock()
+	rr.next.ServeHTTP(w, r)
+}
+
+// find 返回路径以 suffix 结尾的请求
+func (rr *requestRecorder) find(suffix string) []capturedRequest {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	var found []capturedRequest
+	for _, req := range rr.requests {
+		if strings.HasSuffix(req.Path, suffix) {
+			found = append(found, req)
+		}
+	}
+	return found
+}
+
+func TestRegenerateMessageRequestBody(t *testing.T) {
+	tests := []struct {
+		name         string
+		clientOpts   []dsk.Option
+		opts         []dsk.CompletionOption
+		wantThinking bool
+		wantSearch   bool
+	}{
+		{"defaults", nil, nil, false, false},
+		{"thinking and search", nil, []dsk.CompletionOption{dsk.WithThinking(), dsk.WithSearch()}, true, true},
+		{"search only", nil, []dsk.CompletionOption{dsk.WithSearch()}, false, true},
+		{"client defaults", []dsk.Option{dsk.WithDefaultThinking(true), dsk.WithDefaultSearch(true)}, nil, true, true},
+		{"call overrides client defaults", []dsk.Option{dsk.WithDefaultThinking(true), dsk.WithDefaultSearch(true)},
+			[]dsk.CompletionOption{dsk.WithoutThinking(), dsk.WithoutSearch()}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &requestRecorder{next: dsktest.NewHandler(dsktest.MockOptions{})}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+			api := newTestClient(t, srv, tt.clientOpts...)
+
+			if _, err := drain(api.RegenerateMessage(context.Background(), dsktest.SessionID, "42", tt.opts...)); err != nil {
+				t.Fatalf("RegenerateMessage: %v", err)
+			}
+
+			requests := recorder.find("/chat/regenerate")
+			if len(requests) != 1 {
+				t.Fatalf("server received %d regenerate requests, want 1", len(requests))
+			}
+			body := requests[0].Body
+			if body["thinking_enabled"] != tt.wantThinking || body["search_enabled"] != tt.wantSearch {
+				t.Fatalf("thinking_enabled = %v, search_enabled = %v; want %v, %v",
+					body["thinking_enabled"], body["search_enabled"], tt.wantThinking, tt.wantSearch)
+			}
+			if body["chat_session_id"] != dsktest.SessionID || body["message_id"] != "42" {
+				t.Fatalf("chat_session_id = %v, message_id = %v", body["chat_session_id"], body["message_id"])
+			}
+			if _, ok := body["prompt"]; ok {
+				t.Fatalf("regenerate request contains a prompt: %v", body)
+			}
+		})
+	}
+}
+
+func TestCompletePassesDifficultyUnchanged(t *testing.T) {
+	// difficulty 按服务端下发的 JSON 数字原样解码为 float64，不经过 int 转换
+	tests := []string{"144000", "144000.5", "0.25", "4294967296.75", "1e15", "1.5e300"}
+
+	for _, difficulty := range tests {
+		t.Run(difficulty, func(t *testing.T) {
+			want, err := strconv.ParseFloat(difficulty, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := dsktest.NewHandler(dsktest.MockOptions{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/chat/create_pow_challenge") {
+					handler.ServeHTTP(w, r)
+					return
+				}
+				// 与抓包得到的响应格式相同，只替换 difficulty
+				fmt.Fprintf(w, `{"code":0,"msg":"","data":{"biz_code":0,"biz_msg":"","biz_data":{"challenge":{`+
+					`"algorithm":"DeepSeekHashV1","challenge":%q,"salt":"dsktest","signature":"dsktest-signature",`+
+					`"difficulty":%s,"expire_at":4102444800000,"expire_after":300000,"target_path":"/api/v0/chat/completion"}}}}`,
+					dsktest.Challenge.Challenge, difficulty)
+			}))
+			defer srv.Close()
+
+			solver := &recordingSolver{}
+			if _, err := complete(t, newTestClient(t, srv, dsk.WithPoWSolver(solver))); err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if len(solver.configs) != 1 {
+				t.Fatalf("solver called %d times, want 1", len(solver.configs))
+			}
+			if got := solver.configs[0].Difficulty; got != want {
+				t.Fatalf("solver received difficulty %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestCompleteForbidden(t *testing.T) {
+	const body = `{"code":40301,"msg":"PoW verification failed"}`
+	tests := []struct {
+		name string
+		opts dsktest.MockOptions
+	}{
+		{"completion", dsktest.MockOptions{CompletionStatus: http.StatusForbidden, ErrorBody: body}},
+		{"challenge", dsktest.MockOptions{ChallengeStatus: http.StatusForbidden, ErrorBody: body}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := dsktest.NewMockServer(tt.opts)
+			defer srv.Close()
+
+			_, err := complete(t, newTestClient(t, srv))
+			if !errors.Is(err, dsk.ErrForbidden) {
+				t.Fatalf("Complete error = %v, want ErrForbidden", err)
+			}
+			// 403 不是 token 的问题，不能被当作认证失败
+			if errors.Is(err, dsk.ErrUnauthorized) {
+				t.Fatalf("Complete error = %v also matches ErrUnauthorized", err)
+			}
+			var apiErr *dsk.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("Complete error = %T, want *dsk.APIError in the chain", err)
+			}
+			if apiErr.StatusCode != http.StatusForbidden || apiErr.Body != body {
+				t.Fatalf("APIError status = %d, body = %q", apiErr.StatusCode, apiErr.Body)
+			}
+			if got := apiErr.Response.Text(); got != "PoW verification failed" {
+				t.Fatalf("APIError.Response.Text() = %q", got)
+			}
+		})
+	}
+}
+
+func TestCreateChatSessionForbidden(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{SessionStatus: http.StatusForbidden, ErrorBody: "blocked"})
+	defer srv.Close()
+
+	_, err := newTestClient(t, srv).CreateChatSessionContext(context.Background())
+	if !errors.Is(err, dsk.ErrForbidden) {
+		t.Fatalf("CreateChatSessionContext error = %v, want ErrForbidden", err)
+	}
+}
+
+// sseWithIDs 把事件编号为 firstID、firstID+1……后按 SSE 格式拼接
+func sseWithIDs(firstID int, events ...string) string {
+	var sb strings.Builder
+	for i, event := range events {
+		fmt.Fprintf(&sb, "id: %d\ndata: %s\n\n", firstID+i, event)
+	}
+	return sb.String()
+}
+
+func TestCompleteReconnect(t *testing.T) {
+	first := sseWithIDs(1, dsktest.TextEvent("Hel"), dsktest.TextEvent("lo"))
+	tests := []struct {
+		name   string
+		abort  bool   // 第一次连接是否异常中断，否则正常关闭但没有结束信号
+		second string // 第二次连接发送的内容
+		want   string
+		// wantRestart 服务端是否忽略了 Last-Event-ID、从头重新生成
+		wantRestart bool
+	}{
+		{"resumed after abort", true, sseWithIDs(3, dsktest.TextEvent(" world"), dsktest.StopEvent()), "Hello world", false},
+		{"resumed after early close", false, sseWithIDs(3, dsktest.TextEvent(" world"), dsktest.StopEvent()), "Hello world", false},
+		{"server restarts with the same ids", true, sseWithIDs(1, dsktest.TextEvent("Hi"), dsktest.TextEvent(" there"), dsktest.StopEvent()), "Hi there", true},
+		{"server restarts without ids", false, "data: " + dsktest.TextEvent("Hi there") + "\n\ndata: " + dsktest.StopEvent() + "\n\n", "Hi there", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := []http.Handler{
+				dsktest.NewHandler(dsktest.MockOptions{RawStream: first}),
+				dsktest.NewHandler(dsktest.MockOptions{RawStream: tt.second}),
+			}
+			var completions atomic.Int32
+			recorder := &requestRecorder{next: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/chat/completion") {
+					streams[0].ServeHTTP(w, r)
+					return
+				}
+				n := completions.Add(1)
+				streams[n-1].ServeHTTP(w, r)
+				if n == 1 && tt.abort {
+					// 发出已经写入的事件后中断连接，客户端读取时得到 unexpected EOF
+					w.(http.Flusher).Flush()
+					panic(http.ErrAbortHandler)
+				}
+			})}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+
+			api := newTestClient(t, srv, dsk.WithStreamReconnect(1))
+			var stats dsk.StreamStats
+			chunks, err := complete(t, api, dsk.WithStreamStats(&stats))
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+
+			requests := recorder.find("/chat/completion")
+			if len(requests) != 2 {
+				t.Fatalf("server received %d completion requests, want 2", len(requests))
+			}
+			if got := requests[0].Header.Get("Last-Event-ID"); got != "" {
+				t.Fatalf("first request sent Last-Event-ID %q", got)
+			}
+			if got := requests[1].Header.Get("Last-Event-ID"); got != "2" {
+				t.Fatalf("reconnect sent Last-Event-ID %q, want %q", got, "2")
+			}
+			if n := len(recorder.find("/chat/create_pow_challenge")); n != 2 {
+				t.Fatalf("fetched %d PoW challenges, want a fresh one for the reconnect", n)
+			}
+			if stats.Reconnects != 1 {
+				t.Fatalf("StreamStats.Reconnects = %d, want 1", stats.Reconnects)
+			}
+
+			restarted := false
+			for _, chunk := range chunks {
+				restarted = restarted || chunk.Type == "restart"
+			}
+			if restarted != tt.wantRestart {
+				t.Fatalf("restart chunk sent = %v, want %v", restarted, tt.wantRestart)
+			}
+			if last := chunks[len(chunks)-1]; last.FinishReason != dsk.FinishReasonStop {
+				t.Fatalf("last chunk finish reason = %q, want %q", last.FinishReason, dsk.FinishReasonStop)
+			}
+
+			// 重连后再次请求，CollectResponse 丢弃重新生成之前的内容
+			completions.Store(0)
+			text, err := dsk.CollectResponse(api.Complete(context.Background(), dsktest.SessionID, "hi"))
+			if err != nil || text != tt.want {
+				t.Fatalf("CollectResponse = %q, %v; want %q", text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteWithoutReconnect(t *testing.T) {
+	handler := dsktest.NewHandler(dsktest.MockOptions{RawStream: sseWithIDs(1, dsktest.TextEvent("Hel"))})
+	var completions atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+		if strings.HasSuffix(r.URL.Path, "/chat/completion") {
+			completions.Add(1)
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	defer srv.Close()
+
+	// 默认不重连，中断的连接直接返回读取错误
+	_, err := complete(t, newTestClient(t, srv))
+	if err == nil || !strings.Contains(err.Error(), "failed to read stream") {
+		t.Fatalf("Complete error = %v, want a read error", err)
+	}
+	if n := completions.Load(); n != 1 {
+		t.Fatalf("server received %d completion requests, want 1", n)
+	}
+}
+
+func TestCompleteLastEventIDRestart(t *testing.T) {
+	tests := []struct {
+		name        string
+		stream      string
+		wantRestart bool
+	}{
+		{"resumed", sseWithIDs(6, dsktest.TextEvent("rest"), dsktest.StopEvent()), false},
+		// 没有 id: 的响应说明服务端不支持恢复，重新生成了回答
+		{"ignored", "data: " + dsktest.TextEvent("full") + "\n\ndata: " + dsktest.StopEvent() + "\n\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &requestRecorder{next: dsktest.NewHandler(dsktest.MockOptions{RawStream: tt.stream})}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+
+			chunks, err := complete(t, newTestClient(t, srv), dsk.WithLastEventID("5"))
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if got := recorder.find("/chat/completion")[0].Header.Get("Last-Event-ID"); got != "5" {
+				t.Fatalf("Last-Event-ID = %q, want %q", got, "5")
+			}
+			if restarted := chunks[0].Type == "restart"; restarted != tt.wantRestart {
+				t.Fatalf("first chunk = %v, want restart = %v", chunks[0], tt.wantRestart)
+			}
+		})
+	}
+}
+
+func TestCompleteCommentsAndEmptyEvents(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		RawStream: ": keep-alive\n\n" +
+			"data: \n\n" +
+			": ping\n" +
+			"data: " + dsktest.TextEvent("Hello") + "\n\n" +
+			"data:\n\n" +
+			":\n\n" +
+			"data: " + dsktest.StopEvent() + "\n\n",
+	})
+	defer srv.Close()
+
+	// 心跳不算解析错误，即使只允许 1 次也不会中断
+	api := newTestClient(t, srv, dsk.WithMaxParseErrors(1))
+	var stats dsk.StreamStats
+	chunks, err := complete(t, api, dsk.WithStreamStats(&stats))
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != "Hello" {
+		t.Fatalf("answer = %q, want %q", got, "Hello")
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("got %d chunks, want the text and the finish chunk: %v", len(chunks), chunks)
+	}
+	if stats.Comments != 3 {
+		t.Fatalf("StreamStats.Comments = %d, want 3", stats.Comments)
+	}
+	if stats.DataLines != 4 {
+		t.Fatalf("StreamStats.DataLines = %d, want 4", stats.DataLines)
+	}
+	// 每个注释、data 行和分隔事件的空行各算一行
+	if stats.Lines != 13 {
+		t.Fatalf("StreamStats.Lines = %d, want 13", stats.Lines)
+	}
+}
+
+func TestCompleteOnlyComments(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{RawStream: ": keep-alive\n\n: keep-alive\n\n"})
+	defer srv.Close()
+
+	var stats dsk.StreamStats
+	_, err := complete(t, newTestClient(t, srv), dsk.WithStreamStats(&stats))
+	if !errors.Is(err, dsk.ErrNoData) {
+		t.Fatalf("Complete error = %v, want ErrNoData", err)
+	}
+	if stats.Comments != 2 || stats.DataLines != 0 {
+		t.Fatalf("StreamStats = %+v, want 2 comments and no data lines", stats)
+	}
+}
+
+// slowWriter 每次 Flush 之后等待 delay，模拟输出缓慢但持续的服务端；客户端断开后不再等待
+type slowWriter struct {
+	http.ResponseWriter
+	ctx   context.Context
+	delay time.Duration
+}
+
+func (w *slowWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+	select {
+	case <-time.After(w.delay):
+	case <-w.ctx.Done():
+	}
+}
+
+func TestCompleteIdleTimeoutIsSlidingWindow(t *testing.T) {
+	const idleTimeout = 150 * time.Millisecond
+	tests := []struct {
+		name     string
+		delay    time.Duration // 事件之间的间隔
+		wantIdle bool
+	}{
+		// 10 个事件共约 500ms，远超空闲超时，但每次间隔都小于超时
+		{"slow but steady", 50 * time.Millisecond, false},
+		{"stalled", 2 * idleTimeout, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make([]string, 0, 10)
+			for i := 0; i < 9; i++ {
+				events = append(events, dsktest.TextEvent("."))
+			}
+			events = append(events, dsktest.StopEvent())
+			handler := dsktest.NewHandler(dsktest.MockOptions{Events: events})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/chat/completion") {
+					w = &slowWriter{ResponseWriter: w, ctx: r.Context(), delay: tt.delay}
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			api := newTestClient(t, srv, dsk.WithStreamIdleTimeout(idleTimeout))
+			start := time.Now()
+			chunks, err := complete(t, api)
+			elapsed := time.Since(start)
+
+			if tt.wantIdle {
+				if !errors.Is(err, dsk.ErrStreamIdle) {
+					t.Fatalf("Complete error = %v, want ErrStreamIdle", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if elapsed < 3*idleTimeout {
+				t.Fatalf("stream finished after %s, want it to outlast the idle timeout several times", elapsed)
+			}
+			if got := answerText(chunks); got != "........." {
+				t.Fatalf("answer = %q, want 9 dots", got)
+			}
+		})
+	}
+}
+
+// blockingSolver 一直等到 ctx 结束才返回，模拟耗时很长的 PoW
+type blockingSolver struct{}
+
+func (blockingSolver) SolveChallenge(ctx context.Context, config dsk.ChallengeConfig) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestCompleteCompletionTimeout(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+
+	// 挑战没有解且难度很大，真实的 WASM 求解器不被中断时需要运行很长时间
+	unsolvable := dsktest.Challenge
+	unsolvable.Salt = "no-solution"
+	unsolvable.Difficulty = 1e9
+	pow, err := dsk.NewDeepSeekPOW("")
+	if err != nil {
+		t.Fatalf("NewDeepSeekPOW: %v", err)
+	}
+	defer pow.Close()
+
+	steady := make([]string, 0, 20)
+	for i := 0; i < 20; i++ {
+		steady = append(steady, dsktest.TextEvent("."))
+	}
+
+	tests := []struct {
+		name       string
+		challenge  *dsk.ChallengeConfig // 不为 nil 时代替模拟服务器的挑战
+		events     []string
+		solver     dsk.PoWSolver
+		wantChunks bool // 超时前是否已经收到数据块
+	}{
+		// 每 50ms 输出一次，不会触发空闲超时，但总时长超过限制
+		{"steady stream", nil, steady, dsktest.StubSolver{}, true},
+		{"custom solver", nil, nil, blockingSolver{}, false},
+		{"wasm solver", &unsolvable, nil, pow, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := dsktest.NewHandler(dsktest.MockOptions{Events: tt.events})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/chat/create_pow_challenge") && tt.challenge != nil:
+					json.NewEncoder(w).Encode(map[string]interface{}{
+						"code": 0,
+						"data": map[string]interface{}{"biz_data": map[string]interface{}{"challenge": tt.challenge}},
+					})
+					return
+				case strings.HasSuffix(r.URL.Path, "/chat/completion"):
+					w = &slowWriter{ResponseWriter: w, ctx: r.Context(), delay: 50 * time.Millisecond}
+				}
+				handler.ServeHTTP(w, r)
+			}))
+			defer srv.Close()
+
+			api := newTestClient(t, srv,
+				dsk.WithPoWSolver(tt.solver),
+				dsk.WithCompletionTimeout(timeout),
+				dsk.WithStreamIdleTimeout(time.Second))
+			start := time.Now()
+			chunks, err := complete(t, api)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, dsk.ErrCompletionTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Complete error = %v, want ErrCompletionTimeout and context.DeadlineExceeded", err)
+			}
+			if errors.Is(err, dsk.ErrStreamIdle) {
+				t.Fatalf("Complete error = %v also matches ErrStreamIdle", err)
+			}
+			if elapsed > 2*time.Second {
+				t.Fatalf("Complete returned after %s, want shortly after the %s timeout", elapsed, timeout)
+			}
+			if got := len(chunks) > 0; got != tt.wantChunks {
+				t.Fatalf("received %d chunks before the timeout, want chunks = %v", len(chunks), tt.wantChunks)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package dsk
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeContentEncoding 解压带有 Content-Encoding 的响应体
+// 请求没有手动设置 Accept-Encoding 时 net/http 会自动协商并解压 gzip（此时 resp.Uncompressed 为 true）；
+// 这里处理的是代理或拦截器导致响应仍然是压缩数据的情况，支持 gzip 和 deflate
+func decodeContentEncoding(resp *http.Response) error {
+	if resp.Uncompressed {
+		return nil
+	}
+
+	var (
+		body io.ReadCloser
+		err  error
+	)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		body, err = gzip.NewReader(resp.Body)
+	case "deflate":
+		// HTTP 的 deflate 编码是带 zlib 头的数据
+		body, err = zlib.NewReader(resp.Body)
+	default:
+		return nil
+	}
+	if err != nil {
+		// 空响应体（例如部分错误响应）无法读取压缩头，保持原样
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("failed to decode %s response: %w", resp.Header.Get("Content-Encoding"), err)
+	}
+
+	resp.Body = &decodedBody{Reader: body, decoder: body, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// decodedBody 关闭时同时关闭解压器和原始响应体
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	raw     io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	b.decoder.Close()
+	return b.raw.Close()
+}
@@ -0,0 +1,157 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Conversation 绑定到一个会话的多轮对话，自动记录上一条回答的消息 ID 作为下一轮的父消息
+// 同一时间只应有一轮 Ask 在进行，否则两轮会使用同一个父消息，形成对话分支
+type Conversation struct {
+	api       APIClient
+	sessionID string
+
+	mu            sync.Mutex
+	lastMessageID string
+}
+
+// NewConversation 在已有会话上创建多轮对话，api 通常为 *DeepSeekAPI
+func NewConversation(api APIClient, chatSessionID string) *Conversation {
+	return &Conversation{api: api, sessionID: chatSessionID}
+}
+
+// StartConversation 创建新会话并返回绑定到该会话的多轮对话
+func (api *DeepSeekAPI) StartConversation(ctx context.Context) (*Conversation, error) {
+	id, err := api.CreateChatSessionContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewConversation(api, id), nil
+}
+
+// SessionID 返回对话所在的会话 ID
+func (c *Conversation) SessionID() string {
+	return c.sessionID
+}
+
+// LastMessageID 返回上一轮回答的消息 ID，还没有完成任何一轮时为空
+func (c *Conversation) LastMessageID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastMessageID
+}
+
+// Ask 发送下一轮消息，自动以上一轮回答作为父消息，opts 可以覆盖这一设置
+// 只有这一轮完整结束且没有错误时才会更新父消息；读取方需要读完 chunkChan，
+// 否则这一轮不会被记录
+func (c *Conversation) Ask(ctx context.Context, prompt string, opts ...CompletionOption) (<-chan Chunk, <-chan error) {
+	c.mu.Lock()
+	parent := c.lastMessageID
+	c.mu.Unlock()
+
+	if parent != "" {
+		opts = append([]CompletionOption{WithParent(parent)}, opts...)
+	}
+	chunkChan, errChan := c.api.Complete(ctx, c.sessionID, prompt, opts...)
+	return c.track(ctx, chunkChan, errChan)
+}
+
+// Edit 修改这个会话中已发送的用户消息并重新生成回答，见 DeepSeekAPI.EditMessage
+// 与 Ask 相同，这一轮完整结束且没有错误时，后续的 Ask 会在新的分支上继续
+func (c *Conversation) Edit(ctx context.Context, messageID, newPrompt string, opts ...CompletionOption) (<-chan Chunk, <-chan error) {
+	chunkChan, errChan := c.api.EditMessage(ctx, c.sessionID, messageID, newPrompt, opts...)
+	return c.track(ctx, chunkChan, errChan)
+}
+
+// Regenerate 重新生成这个会话中的一条回答，见 DeepSeekAPI.RegenerateMessage
+// 与 Ask 相同，这一轮完整结束且没有错误时，后续的 Ask 会以新回答为父消息继续
+func (c *Conversation) Regenerate(ctx context.Context, messageID string, opts ...CompletionOption) (<-chan Chunk, <-chan error) {
+	chunkChan, errChan := c.api.RegenerateMessage(ctx, c.sessionID, messageID, opts...)
+	return c.track(ctx, chunkChan, errChan)
+}
+
+// track 转发一轮对话的响应，成功结束后把最后的消息 ID 记录为下一轮的父消息
+func (c *Conversation) track(ctx context.Context, chunkChan <-chan Chunk, errChan <-chan error) (<-chan Chunk, <-chan error) {
+	outChan := make(chan Chunk, 10)
+	outErrChan := make(chan error, 1)
+
+	go func() {
+		defer close(outChan)
+		defer close(outErrChan)
+
+		var messageID string
+		for chunk := range chunkChan {
+			if chunk.MessageID != "" {
+				messageID = chunk.MessageID
+			}
+			select {
+			case outChan <- chunk:
+				continue
+			default:
+			}
+			select {
+			case outChan <- chunk:
+			case <-ctx.Done():
+				outErrChan <- ctx.Err()
+				// 排空上游，让它的 goroutine 能够退出
+				for range chunkChan {
+				}
+				<-errChan
+				return
+			}
+		}
+
+		if err := <-errChan; err != nil {
+			outErrChan <- err
+			return
+		}
+
+		if messageID != "" {
+			c.mu.Lock()
+			c.lastMessageID = messageID
+			c.mu.Unlock()
+		}
+	}()
+
+	return outChan, outErrChan
+}
+
+// importInstruction 导入对话记录时附加在记录之后的说明，让模型只确认而不续写
+const importInstruction = "The messages above are an earlier conversation. Treat them as our conversation history and reply only with \"OK\"."
+
+// ImportConversation 创建新会话并导入对话记录，返回会话 ID，详见 ImportAsConversation
+func (api *DeepSeekAPI) ImportConversation(ctx context.Context, transcript []Message) (string, error) {
+	conv, err := api.ImportAsConversation(ctx, transcript)
+	if err != nil {
+		return "", err
+	}
+	return conv.SessionID(), nil
+}
+
+// ImportAsConversation 创建新会话并导入对话记录，返回可以继续 Ask 的 Conversation
+//
+// 网页版接口不能直接写入历史消息，尤其不能伪造 assistant 的回答，因此导入的方式是：
+// 把整段记录按 BuildPrompt 的格式合并为一条消息发送，并要求模型只回复确认，
+// 随后的 Ask 以这条确认作为父消息继续对话。限制如下：
+//   - 导入会消耗一次真实的对话请求（包括 PoW），模型的确认回复会留在会话中
+//   - 服务端会话中只有一条合并后的消息，网页版界面看不到原来的轮次，模型看到的是文本形式的历史
+//   - 原对话中的思考过程、搜索结果和文件引用都不会被导入
+//
+// transcript 为空时只创建会话
+func (api *DeepSeekAPI) ImportAsConversation(ctx context.Context, transcript []Message) (*Conversation, error) {
+	conv, err := api.StartConversation(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(transcript) == 0 {
+		return conv, nil
+	}
+
+	messages := append(append([]Message(nil), transcript...), Message{Role: RoleUser, Content: importInstruction})
+	if _, err := CollectResponse(conv.Ask(ctx, BuildPrompt(messages))); err != nil {
+		return nil, fmt.Errorf("failed to replay transcript: %w", err)
+	}
+
+	return conv, nil
+}
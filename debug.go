@@ -5,14 +5,98 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 )
 
 // EnableDebug 启用调试模式，打印详细的请求和响应信息
 var EnableDebug = false
 
+var (
+	debugWriterMu sync.RWMutex
+	debugWriter   io.Writer = os.Stderr
+)
+
+// SetDebugWriter 设置调试信息的输出位置，默认为 os.Stderr，传入 nil 恢复默认值
+// 是否输出仍然由 EnableDebug 控制
+func SetDebugWriter(w io.Writer) {
+	if w == nil {
+		w = os.Stderr
+	}
+
+	debugWriterMu.Lock()
+	debugWriter = w
+	debugWriterMu.Unlock()
+}
+
+// Logger 日志接口，可以通过 WithLogger 接入服务自己的结构化日志
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+}
+
+// defaultLogger 默认日志实现，只在 EnableDebug 为 true 时输出到 SetDebugWriter 设置的位置，否则不输出任何内容
+type defaultLogger struct{}
+
+func (defaultLogger) Debugf(format string, args ...interface{}) {
+	debugPrint(format, args...)
+}
+
+func (defaultLogger) Infof(format string, args ...interface{}) {
+	debugPrint(format, args...)
+}
+
+func (defaultLogger) Warnf(format string, args ...interface{}) {
+	debugPrint(format, args...)
+}
+
+// Level 日志级别，级别越高输出越详细
+type Level int
+
+const (
+	LevelOff   Level = iota // 不输出任何日志
+	LevelError              // 只输出错误和警告（Logger.Warnf）
+	LevelInfo               // 输出一般信息（Logger.Infof）
+	LevelDebug              // 输出请求生命周期（Logger.Debugf），默认级别
+	LevelTrace              // 额外输出 SSE 流中的每一行和每个数据块
+)
+
+// levelLogger 按日志级别过滤后再交给 Logger 输出
+type levelLogger struct {
+	Logger
+	level Level
+}
+
+func (l levelLogger) Debugf(format string, args ...interface{}) {
+	if l.level >= LevelDebug {
+		l.Logger.Debugf(format, args...)
+	}
+}
+
+func (l levelLogger) Infof(format string, args ...interface{}) {
+	if l.level >= LevelInfo {
+		l.Logger.Infof(format, args...)
+	}
+}
+
+func (l levelLogger) Warnf(format string, args ...interface{}) {
+	if l.level >= LevelError {
+		l.Logger.Warnf(format, args...)
+	}
+}
+
+// Tracef 输出 Trace 级别的日志，Logger 没有单独的 Trace 方法，使用 Debugf 输出
+func (l levelLogger) Tracef(format string, args ...interface{}) {
+	if l.level >= LevelTrace {
+		l.Logger.Debugf(format, args...)
+	}
+}
+
 func debugPrint(format string, args ...interface{}) {
 	if EnableDebug {
-		fmt.Fprintf(os.Stderr, "[DEBUG] "+format+"\n", args...)
+		debugWriterMu.RLock()
+		defer debugWriterMu.RUnlock()
+		fmt.Fprintf(debugWriter, "[DEBUG] "+format+"\n", args...)
 	}
 }
 
@@ -0,0 +1,236 @@
+// Package dsktest 提供模拟 DeepSeek 网页版接口的测试服务器，
+// 可以在没有真实 token 的情况下测试基于 dsk 编写的代码
+package dsktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+const (
+	// Token 模拟服务器默认接受的 token
+	Token = "dsktest-token"
+	// SessionID 模拟服务器默认返回的会话 ID
+	SessionID = "dsktest-session"
+	// DoneEvent SSE 流的结束标记
+	DoneEvent = "[DONE]"
+)
+
+// Challenge 模拟服务器下发的 PoW 挑战
+// 这是一个真实可解的挑战（答案为 4242），嵌入的 WASM 求解器可以正常求解，
+// expire_at 为 2100 年，不会因为过期被拒绝
+var Challenge = dsk.ChallengeConfig{
+	Algorithm:  "DeepSeekHashV1",
+	Challenge:  "3dbacb26803b2a65dffcfdf9b3d1dd896dae992a7397f4ab478c2a554b55d4d5",
+	Salt:       "dsktest",
+	Difficulty: 144000,
+	ExpireAt:   4102444800000,
+	Signature:  "dsktest-signature",
+	TargetPath: "/api/v0/chat/completion",
+}
+
+// MockOptions 模拟服务器的行为配置
+type MockOptions struct {
+	// Token 服务器接受的 token，为空时使用 Token 常量；请求的 authorization 不匹配时返回 401
+	Token string
+	// SessionID 创建会话时返回的 ID，为空时使用 SessionID 常量
+	SessionID string
+	// Events 对话接口按顺序发送的 SSE data 内容，可以使用 TextEvent 等函数构造；
+	// 包含换行符的内容会拆成多行 data: 发送（例如缩进格式的 JSON），用于测试多行事件；
+	// 为空时发送一条 "Hello from dsktest" 文本、结束事件和 [DONE]
+	Events []string
+	// RawStream 不为空时对话接口代替 Events 原样发送该内容，用于测试不规范的 SSE 格式（例如 data: 后没有空格）
+	RawStream string
+	// ContentType 对话接口响应的 Content-Type，为空时为 text/event-stream
+	ContentType string
+
+	// 以下状态码不为 0 时，对应接口直接返回该状态码和 ErrorBody，用于注入错误
+	ChallengeStatus  int
+	SessionStatus    int
+	CompletionStatus int
+	ErrorBody        string
+}
+
+// NewMockServer 启动模拟服务器，实现 PoW 挑战、会话创建、对话和用户信息接口
+// 接口路径与真实服务一致（/api/v0/...），使用完毕后需要调用 Close
+func NewMockServer(opts MockOptions) *httptest.Server {
+	return httptest.NewServer(NewHandler(opts))
+}
+
+// NewHandler 返回模拟服务器的 http.Handler，可以用中间件包装后自行启动服务器，
+// 例如检查请求头和请求体，或者压缩响应
+func NewHandler(opts MockOptions) http.Handler {
+	if opts.Token == "" {
+		opts.Token = Token
+	}
+	if opts.SessionID == "" {
+		opts.SessionID = SessionID
+	}
+	if len(opts.Events) == 0 {
+		opts.Events = []string{TextEvent("Hello from dsktest"), StopEvent(), DoneEvent}
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "text/event-stream"
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/v0/chat/create_pow_challenge", func(w http.ResponseWriter, r *http.Request) {
+		if !checkRequest(w, r, opts, opts.ChallengeStatus) {
+			return
+		}
+		writeBizData(w, map[string]interface{}{"challenge": Challenge})
+	})
+
+	mux.HandleFunc("/api/v0/chat_session/create", func(w http.ResponseWriter, r *http.Request) {
+		if !checkRequest(w, r, opts, opts.SessionStatus) {
+			return
+		}
+		writeBizData(w, map[string]interface{}{"id": opts.SessionID})
+	})
+
+	// 编辑消息、重新生成与发送消息的响应格式相同
+	completion := func(w http.ResponseWriter, r *http.Request) {
+		if !checkRequest(w, r, opts, opts.CompletionStatus) {
+			return
+		}
+		if r.Header.Get("x-ds-pow-response") == "" {
+			http.Error(w, `{"code":40301,"msg":"missing PoW response"}`, http.StatusForbidden)
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+		w.Header().Set("Content-Type", opts.ContentType)
+		w.WriteHeader(http.StatusOK)
+		if opts.RawStream != "" {
+			fmt.Fprint(w, opts.RawStream)
+			return
+		}
+		for _, event := range opts.Events {
+			// 包含换行符的事件按 SSE 规范拆成多行 data 发送
+			for _, line := range strings.Split(event, "\n") {
+				fmt.Fprintf(w, "data: %s\n", line)
+			}
+			fmt.Fprint(w, "\n")
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	mux.HandleFunc("/api/v0/chat/completion", completion)
+	mux.HandleFunc("/api/v0/chat/edit_message", completion)
+	mux.HandleFunc("/api/v0/chat/regenerate", completion)
+
+	mux.HandleFunc("/api/v0/users/current", func(w http.ResponseWriter, r *http.Request) {
+		if !checkRequest(w, r, opts, 0) {
+			return
+		}
+		writeBizData(w, map[string]interface{}{
+			"id":    "dsktest-user",
+			"email": "dsktest@example.com",
+		})
+	})
+
+	return mux
+}
+
+// BaseURL 返回模拟服务器的 API 地址，用于 dsk.WithBaseURL
+func BaseURL(srv *httptest.Server) string {
+	return srv.URL + "/api/v0"
+}
+
+// NewClient 创建连接到模拟服务器的客户端，使用 Token 常量认证
+// opts 会追加在 WithBaseURL 之后，服务器设置了自定义 MockOptions.Token 时需要自行创建客户端
+func NewClient(srv *httptest.Server, opts ...dsk.Option) (*dsk.DeepSeekAPI, error) {
+	return dsk.NewDeepSeekAPI(Token, append([]dsk.Option{dsk.WithBaseURL(BaseURL(srv))}, opts...)...)
+}
+
+// StubSolver 返回固定结果的 PoW 求解器，配合 dsk.WithPoWSolver 使用可以跳过 WASM 求解
+// 模拟服务器只检查 x-ds-pow-response 请求头是否存在，不校验答案
+type StubSolver struct {
+	Response string // 返回的 PoW 响应，为空时返回 "dsktest-pow"
+	Err      error  // 不为 nil 时返回该错误，用于模拟求解失败
+}
+
+// SolveChallenge 实现 dsk.PoWSolver
+func (s StubSolver) SolveChallenge(ctx context.Context, config dsk.ChallengeConfig) (string, error) {
+	if s.Err != nil {
+		return "", s.Err
+	}
+	if s.Response == "" {
+		return "dsktest-pow", nil
+	}
+	return s.Response, nil
+}
+
+// TextEvent 构造一条回答内容的 SSE data
+func TextEvent(content string) string {
+	return deltaEvent("text", content, "")
+}
+
+// ThinkingEvent 构造一条思考过程的 SSE data
+func ThinkingEvent(content string) string {
+	return deltaEvent("thinking", content, "")
+}
+
+// StopEvent 构造 finish_reason 为 stop 的结束事件
+func StopEvent() string {
+	return deltaEvent("text", "", "stop")
+}
+
+// ContentFilterEvent 构造回答被内容审核拦截时的状态事件，客户端收到后返回 dsk.ErrContentFiltered
+func ContentFilterEvent() string {
+	return `{"p":"response/status","o":"SET","v":"CONTENT_FILTER"}`
+}
+
+// deltaEvent 构造 choices[0].delta 格式的 SSE data
+func deltaEvent(chunkType, content, finishReason string) string {
+	choice := map[string]interface{}{
+		"index": 0,
+		"delta": map[string]interface{}{
+			"type":    chunkType,
+			"content": content,
+		},
+	}
+	if finishReason != "" {
+		choice["finish_reason"] = finishReason
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"choices": []interface{}{choice},
+	})
+	return string(data)
+}
+
+// checkRequest 校验 token 并处理错误注入，返回 false 表示已经写出了错误响应
+func checkRequest(w http.ResponseWriter, r *http.Request, opts MockOptions, injectStatus int) bool {
+	if strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ") != opts.Token {
+		http.Error(w, `{"code":40003,"msg":"Authorization Failed (invalid token)"}`, http.StatusUnauthorized)
+		return false
+	}
+	if injectStatus != 0 {
+		http.Error(w, opts.ErrorBody, injectStatus)
+		return false
+	}
+	return true
+}
+
+// writeBizData 以 {"code":0,"data":{"biz_data":...}} 格式输出响应
+func writeBizData(w http.ResponseWriter, bizData interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code": 0,
+		"msg":  "",
+		"data": map[string]interface{}{
+			"biz_code": 0,
+			"biz_msg":  "",
+			"biz_data": bizData,
+		},
+	})
+}
@@ -0,0 +1,232 @@
+package dsk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnauthorized token 无效或已过期（HTTP 401）
+	ErrUnauthorized = errors.New("authentication failed")
+	// ErrForbidden 请求被服务端拒绝（HTTP 403），通常是 PoW 校验失败或触发了反爬虫，而不是 token 无效；
+	// 可以重新求解 PoW 后重试，拒绝原因见 APIError.Response 或 APIError.Body
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited 请求过于频繁（HTTP 429）
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrServerError 服务端错误（HTTP 5xx）
+	ErrServerError = errors.New("server error")
+	// ErrNoData 流式响应中没有收到任何有效数据
+	ErrNoData = errors.New("no data received from stream")
+	// ErrStreamIdle 流式响应在 WithStreamIdleTimeout 设置的时间内没有收到任何数据
+	ErrStreamIdle = errors.New("stream idle timeout")
+	// ErrCompletionTimeout 对话请求的总时长超过了 WithCompletionTimeout 设置的时间
+	ErrCompletionTimeout = errors.New("completion timeout")
+	// ErrLineTooLong 流式响应中的一行超过了 WithMaxLineSize 设置的长度
+	ErrLineTooLong = errors.New("stream line too long")
+	// ErrUsageUnavailable 当前账号无法查询用量（接口不存在或未返回用量数据）
+	ErrUsageUnavailable = errors.New("usage information is not available for this account")
+	// ErrNoTokenAvailable 多 token 客户端的所有 token 都暂时不可用
+	ErrNoTokenAvailable = errors.New("no auth token available")
+	// ErrClosed 客户端已经调用过 Close
+	ErrClosed = errors.New("client is closed")
+	// ErrUnknownAlgorithm PoW 挑战使用了没有注册求解器的算法
+	ErrUnknownAlgorithm = errors.New("unsupported PoW algorithm")
+	// ErrPoW PoW 挑战的获取、求解或编码失败，具体阶段见 PoWError
+	ErrPoW = errors.New("proof of work failed")
+	// ErrFileTooLarge 上传的文件超过 WithMaxUploadSize 设置的大小
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrUnsupportedType 上传的文件类型不在 WithAllowedUploadTypes 允许的范围内
+	ErrUnsupportedType = errors.New("unsupported file type")
+	// ErrContentFiltered 回答被服务端的内容审核拦截，具体原因见 ContentFilterError
+	ErrContentFiltered = errors.New("content filtered")
+	// ErrTooManyParseErrors 流式响应中连续无法解析的事件超过 WithMaxParseErrors 的上限
+	ErrTooManyParseErrors = errors.New("too many consecutive parse errors")
+)
+
+// maxErrorBodyLength APIError 中保留的响应体最大长度
+const maxErrorBodyLength = 500
+
+// APIError 表示 DeepSeek API 返回的非 200 响应
+// 可以通过 errors.As 获取状态码和响应体，也可以通过 errors.Is 判断对应的哨兵错误
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	Body       string // 响应体，过长时会被截断
+	Endpoint   string // 请求的接口路径，例如 /chat/completion
+
+	// Status 和 Header 保留原始响应的状态行和响应头（响应体已读取并关闭），
+	// 用于排查反爬虫拒绝等问题，例如查看 x-ds-* 调试响应头
+	Status string      // 状态行，例如 "403 Forbidden"
+	Header http.Header // 响应头
+
+	RequestID string // 请求的 x-request-id，未设置 WithRequestIDFunc 时为空
+
+	// Code 响应体中的业务错误码（见 CodeInvalidToken 等常量），响应体不是 JSON 或没有 code 字段时为 0
+	// 比匹配错误说明的文字更可靠，可以用于决定是否重试或向用户显示的提示
+	Code int
+
+	// Response 解析后的 JSON 错误响应体，响应体不是 JSON 或不包含错误字段时为 nil，此时只能查看 Body
+	Response *ErrorResponse
+}
+
+// 实际观察到的业务错误码，服务端没有公开文档，可能随时变化
+const (
+	// CodeInvalidToken token 无效或已过期，响应体为 {"code":40003,"msg":"Authorization Failed (invalid token)"}
+	CodeInvalidToken = 40003
+	// CodePoWFailed 缺少 PoW 响应或校验失败，重新求解 PoW 后可以重试
+	CodePoWFailed = 40301
+)
+
+// ErrorResponse DeepSeek 接口的 JSON 错误响应体，例如 {"code":40003,"msg":"Authorization Failed (invalid token)"}
+// 不同接口使用 msg 或 message 字段，Text 返回其中不为空的一个
+type ErrorResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Msg     string `json:"msg"`
+}
+
+// Text 返回错误说明，优先使用 Message；r 为 nil 时返回空字符串
+func (r *ErrorResponse) Text() string {
+	if r == nil {
+		return ""
+	}
+	if r.Message != "" {
+		return r.Message
+	}
+	return r.Msg
+}
+
+// parseErrorResponse 尝试把响应体解析为 ErrorResponse，不是 JSON 对象或没有任何错误字段时返回 nil
+func parseErrorResponse(body []byte) *ErrorResponse {
+	var resp ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	if resp.Code == 0 && resp.Message == "" && resp.Msg == "" {
+		return nil
+	}
+	return &resp
+}
+
+// newAPIError 根据响应创建 APIError，响应体超过 maxErrorBodyLength 时在字符边界处截断
+// body 需要由调用方提前读取，resp.Body 仍由调用方负责关闭
+func newAPIError(endpoint string, resp *http.Response, body []byte) *APIError {
+	bodyStr := strings.TrimSpace(string(body))
+	if len(bodyStr) > maxErrorBodyLength {
+		// 按字节截断可能切开多字节字符（例如中文错误信息），向前找到字符的起始字节
+		end := maxErrorBodyLength
+		for end > 0 && !utf8.RuneStart(bodyStr[end]) {
+			end--
+		}
+		bodyStr = bodyStr[:end] + "..."
+	}
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       bodyStr,
+		Endpoint:   endpoint,
+		Status:     resp.Status,
+		Header:     resp.Header.Clone(),
+		Response:   parseErrorResponse(body),
+	}
+	if apiErr.Response != nil {
+		apiErr.Code = apiErr.Response.Code
+	}
+	if resp.Request != nil {
+		apiErr.RequestID = resp.Request.Header.Get(requestIDHeader)
+	}
+	return apiErr
+}
+
+// Error 实现 error 接口
+func (e *APIError) Error() string {
+	prefix := "request failed"
+	if sentinel := e.Unwrap(); sentinel != nil {
+		prefix = sentinel.Error()
+	}
+
+	msg := fmt.Sprintf("%s: endpoint %s, status %d", prefix, e.Endpoint, e.StatusCode)
+	if e.RequestID != "" {
+		msg += ", request id " + e.RequestID
+	}
+	// 能解析出错误说明时只显示错误码和说明，否则显示原始响应体
+	if e.Response.Text() != "" {
+		msg += fmt.Sprintf(", code %d: %s", e.Code, e.Response.Text())
+	} else if e.Body != "" {
+		msg += ", body: " + e.Body
+	}
+	return msg
+}
+
+// Unwrap 返回与状态码对应的哨兵错误，没有对应的哨兵错误时返回 nil
+// 错误码为 CodeInvalidToken 时无论状态码如何都返回 ErrUnauthorized
+func (e *APIError) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized, e.Code == CodeInvalidToken:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServerError
+	default:
+		return nil
+	}
+}
+
+// PoWStage 表示 PoW 失败发生的阶段
+type PoWStage string
+
+const (
+	PoWStageFetch  PoWStage = "fetch"  // 获取挑战失败
+	PoWStageSolve  PoWStage = "solve"  // WASM 求解失败
+	PoWStageEncode PoWStage = "encode" // 编码响应失败或响应为空
+)
+
+// PoWError 表示 PoW 流程中的错误
+// errors.Is(err, ErrPoW) 对所有 PoWError 成立，Err 中的原始错误也可以继续通过 errors.Is/As 判断
+type PoWError struct {
+	Stage PoWStage
+	Err   error
+}
+
+// Error 实现 error 接口
+func (e *PoWError) Error() string {
+	switch e.Stage {
+	case PoWStageFetch:
+		return fmt.Sprintf("failed to get PoW challenge: %v", e.Err)
+	case PoWStageSolve:
+		return fmt.Sprintf("failed to solve PoW challenge: %v", e.Err)
+	default:
+		return fmt.Sprintf("failed to encode PoW response: %v", e.Err)
+	}
+}
+
+// Unwrap 返回原始错误
+func (e *PoWError) Unwrap() error {
+	return e.Err
+}
+
+// Is 使 errors.Is(err, ErrPoW) 成立
+func (e *PoWError) Is(target error) bool {
+	return target == ErrPoW
+}
+
+// ContentFilterError 流式响应中途被内容审核拦截时返回的错误，
+// 拦截前已经发送的数据块仍然有效；可以通过 errors.Is(err, ErrContentFiltered) 判断
+type ContentFilterError struct {
+	Reason string // 服务端给出的拦截原因，例如 "CONTENT_FILTER" 或 "content_filter"
+}
+
+// Error 实现 error 接口
+func (e *ContentFilterError) Error() string {
+	return fmt.Sprintf("content filtered: %s", e.Reason)
+}
+
+// Is 使 errors.Is(err, ErrContentFiltered) 成立
+func (e *ContentFilterError) Is(target error) bool {
+	return target == ErrContentFiltered
+}
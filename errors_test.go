@@ -0,0 +1,41 @@
+package dsk
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestNewAPIErrorTruncatesAtRuneBoundary(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Header: http.Header{}}
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"ascii", strings.Repeat("a", maxErrorBodyLength*2)},
+		// 每个汉字 3 个字节，前面加 1 个字节让截断位置落在字符中间
+		{"multibyte", "x" + strings.Repeat("错误", maxErrorBodyLength)},
+		{"short", "错误"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := newAPIError("/chat/completion", resp, []byte(tt.body))
+			if !utf8.ValidString(apiErr.Body) {
+				t.Fatalf("Body is not valid UTF-8: %q", apiErr.Body)
+			}
+			body := strings.TrimSuffix(apiErr.Body, "...")
+			if len(body) > maxErrorBodyLength {
+				t.Fatalf("Body length = %d, want <= %d", len(body), maxErrorBodyLength)
+			}
+			if !strings.HasPrefix(tt.body, body) {
+				t.Fatalf("Body %q is not a prefix of the response body", body)
+			}
+			if apiErr.StatusCode != http.StatusBadRequest || apiErr.Endpoint != "/chat/completion" {
+				t.Fatalf("StatusCode = %d, Endpoint = %q", apiErr.StatusCode, apiErr.Endpoint)
+			}
+		})
+	}
+}
@@ -6,4 +6,8 @@ replace github.com/minchieh-fay/dsk => ../
 
 require github.com/minchieh-fay/dsk v0.0.0-00010101000000-000000000000
 
-require github.com/tetratelabs/wazero v1.7.0 // indirect
+require (
+	github.com/tetratelabs/wazero v1.7.0 // indirect
+	golang.org/x/net v0.35.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -31,7 +32,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Error creating API client: %v\n", err)
 		os.Exit(1)
 	}
-	defer api.Close()
+	defer api.Close(context.Background())
 
 	// 创建聊天会话
 	fmt.Println("Creating chat session...")
@@ -79,6 +80,8 @@ func main() {
 				fmt.Print(chunk.Content)
 			} else if chunk.Type == "thinking" {
 				fmt.Printf("\n[Thinking: %s]\n", chunk.Content)
+			} else if chunk.Type == "answer_start" {
+				fmt.Println("\n[Answer]")
 			} else if chunk.Type != "" || chunk.Content != "" {
 				fmt.Printf("\n[Type: %s, Content: %s]\n", chunk.Type, chunk.Content)
 			}
@@ -0,0 +1,69 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+)
+
+// Feedback 对回答的评价，与网页版回答下方的点赞和点踩按钮对应
+type Feedback int
+
+const (
+	// FeedbackUp 点赞
+	FeedbackUp Feedback = iota + 1
+	// FeedbackDown 点踩
+	FeedbackDown
+)
+
+// String 返回服务端使用的评价类型
+func (f Feedback) String() string {
+	switch f {
+	case FeedbackUp:
+		return "LIKE"
+	case FeedbackDown:
+		return "DISLIKE"
+	default:
+		return fmt.Sprintf("Feedback(%d)", int(f))
+	}
+}
+
+// SendFeedback 对会话中的一条回答点赞或点踩，comment 为可选的文字说明
+// messageID 为回答的消息 ID（Chunk.MessageID），不是用户消息的 ID
+func (api *DeepSeekAPI) SendFeedback(ctx context.Context, chatSessionID, messageID string, rating Feedback, comment string) error {
+	if chatSessionID == "" || messageID == "" {
+		return fmt.Errorf("chat session id and message id are required")
+	}
+	if rating != FeedbackUp && rating != FeedbackDown {
+		return fmt.Errorf("invalid feedback: %v", rating)
+	}
+
+	ctx, err := api.pinToken(ctx, chatSessionID)
+	if err != nil {
+		return err
+	}
+
+	resp, err := api.makeRequest(ctx, "POST", "/chat/message_feedback", map[string]interface{}{
+		"chat_session_id": chatSessionID,
+		"message_id":      messageID,
+		"feedback":        rating.String(),
+		"comment":         comment,
+	}, false)
+	if err != nil {
+		return err
+	}
+
+	// 服务端只在拒绝时返回 success: false，没有 biz_data 或该字段时视为成功
+	if data, ok := resp["data"].(map[string]interface{}); ok && data["biz_data"] == nil {
+		return nil
+	}
+	var result struct {
+		Success *bool `json:"success"`
+	}
+	if err := decodeBizData(resp, &result); err != nil {
+		return err
+	}
+	if result.Success != nil && !*result.Success {
+		return fmt.Errorf("feedback was not accepted")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package dsk_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+func TestSendFeedback(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string // 服务端返回的响应
+		wantErr bool
+	}{
+		{"no biz_data", `{"code":0,"data":{"biz_code":0,"biz_msg":"","biz_data":null}}`, false},
+		{"success missing", `{"code":0,"data":{"biz_code":0,"biz_data":{}}}`, false},
+		{"success true", `{"code":0,"data":{"biz_code":0,"biz_data":{"success":true}}}`, false},
+		{"success false", `{"code":0,"data":{"biz_code":0,"biz_data":{"success":false}}}`, true},
+		{"data missing", `{"code":0}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/v0/chat/message_feedback" {
+					http.NotFound(w, r)
+					return
+				}
+				raw, _ := io.ReadAll(r.Body)
+				json.Unmarshal(raw, &got)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			err := newTestClient(t, srv).SendFeedback(context.Background(), "session-1", "2", dsk.FeedbackDown, "答案不完整")
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("SendFeedback error = %v, want error %v", err, tt.wantErr)
+			}
+			want := map[string]interface{}{
+				"chat_session_id": "session-1",
+				"message_id":      "2",
+				"feedback":        "DISLIKE",
+				"comment":         "答案不完整",
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Fatalf("request %s = %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestSendFeedbackInvalidArguments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	defer srv.Close()
+	api := newTestClient(t, srv)
+
+	tests := []struct {
+		name                 string
+		sessionID, messageID string
+		rating               dsk.Feedback
+	}{
+		{"missing session", "", "2", dsk.FeedbackUp},
+		{"missing message", "session-1", "", dsk.FeedbackUp},
+		{"invalid rating", "session-1", "2", dsk.Feedback(0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := api.SendFeedback(context.Background(), tt.sessionID, tt.messageID, tt.rating, ""); err == nil {
+				t.Fatal("SendFeedback succeeded, want an error")
+			}
+		})
+	}
+}
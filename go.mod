@@ -2,4 +2,9 @@ module github.com/minchieh-fay/dsk
 
 go 1.21
 
-require github.com/tetratelabs/wazero v1.7.0
+require (
+	github.com/tetratelabs/wazero v1.7.0
+	golang.org/x/net v0.35.0
+)
+
+require golang.org/x/text v0.22.0 // indirect
@@ -0,0 +1,198 @@
+package dsk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryMessage 会话历史中的一条消息
+type HistoryMessage struct {
+	MessageID       string    // 消息 ID，可以作为 parentMessageID 继续对话
+	ParentID        string    // 父消息 ID，会话的第一条消息为空
+	Role            string    // RoleUser 或 RoleAssistant
+	Content         string    // 消息内容
+	ThinkingContent string    // 思考过程，只有开启思考的回答才有
+	InsertedAt      time.Time // 消息创建时间，未返回时为零值
+}
+
+// MessageHistory 会话的标题和消息历史
+type MessageHistory struct {
+	Title    string           // 会话标题，DeepSeek 在第一轮对话后自动生成
+	Messages []HistoryMessage // 按时间顺序排列的消息
+}
+
+// MessagePage 分页获取的一页会话历史
+type MessagePage struct {
+	Messages   []HistoryMessage // 按时间顺序排列的消息
+	NextCursor string           // 下一页的游标，为空表示没有更多消息
+}
+
+// historyResponse 会话历史接口的 biz_data
+type historyResponse struct {
+	ChatSession struct {
+		Title string `json:"title"`
+	} `json:"chat_session"`
+	ChatMessages []struct {
+		MessageID       json.Number `json:"message_id"`
+		ParentID        json.Number `json:"parent_id"`
+		Role            string      `json:"role"`
+		Content         string      `json:"content"`
+		ThinkingContent string      `json:"thinking_content"`
+		InsertedAt      float64     `json:"inserted_at"`
+	} `json:"chat_messages"`
+	NextCursor historyCursor `json:"next_cursor"`
+	HasMore    bool          `json:"has_more"`
+}
+
+// historyCursor 分页游标，服务端可能返回数字或不透明的字符串，统一保存为字符串
+type historyCursor string
+
+// UnmarshalJSON 接受字符串、数字和 null
+func (c *historyCursor) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		*c = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*c = historyCursor(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return fmt.Errorf("invalid cursor %s", b)
+	}
+	*c = historyCursor(n)
+	return nil
+}
+
+// fetchHistory 请求会话历史，cursor 为空且 limit 为 0 时不发送分页参数
+func (api *DeepSeekAPI) fetchHistory(ctx context.Context, chatSessionID, cursor string, limit int) (historyResponse, error) {
+	query := url.Values{"chat_session_id": {chatSessionID}}
+	if cursor != "" {
+		query.Set("cursor", cursor)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	resp, err := api.makeRequest(ctx, "GET", "/chat/history_messages?"+query.Encode(), nil, false)
+	if err != nil {
+		return historyResponse{}, err
+	}
+
+	var history historyResponse
+	if err := decodeBizData(resp, &history); err != nil {
+		return historyResponse{}, err
+	}
+	return history, nil
+}
+
+// messages 把接口返回的消息转换为 HistoryMessage
+func (h historyResponse) messages() []HistoryMessage {
+	messages := make([]HistoryMessage, 0, len(h.ChatMessages))
+	for _, msg := range h.ChatMessages {
+		m := HistoryMessage{
+			MessageID:       msg.MessageID.String(),
+			ParentID:        msg.ParentID.String(),
+			Role:            strings.ToLower(msg.Role),
+			Content:         msg.Content,
+			ThinkingContent: msg.ThinkingContent,
+		}
+		if msg.InsertedAt > 0 {
+			m.InsertedAt = time.Unix(0, int64(msg.InsertedAt*float64(time.Second)))
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// FetchMessageHistory 获取会话的消息历史
+func (api *DeepSeekAPI) FetchMessageHistory(ctx context.Context, chatSessionID string) (MessageHistory, error) {
+	history, err := api.fetchHistory(ctx, chatSessionID, "", 0)
+	if err != nil {
+		return MessageHistory{}, err
+	}
+
+	return MessageHistory{
+		Title:    history.ChatSession.Title,
+		Messages: history.messages(),
+	}, nil
+}
+
+// FetchMessageHistoryPage 分页获取会话的消息历史，适合消息很多的会话，cursor 为空时从第一页开始，
+// 之后传入上一页的 NextCursor；limit 为每页的消息数，小于等于 0 时使用服务端的默认值
+// 服务端不支持分页时会在第一页返回全部消息，NextCursor 为空；
+// 服务端表示还有更多消息但没有返回游标时，以本页最后一条消息的 ID 作为 NextCursor
+func (api *DeepSeekAPI) FetchMessageHistoryPage(ctx context.Context, chatSessionID, cursor string, limit int) (MessagePage, error) {
+	history, err := api.fetchHistory(ctx, chatSessionID, cursor, limit)
+	if err != nil {
+		return MessagePage{}, err
+	}
+
+	page := MessagePage{
+		Messages:   history.messages(),
+		NextCursor: string(history.NextCursor),
+	}
+	if page.NextCursor == "" && history.HasMore && len(page.Messages) > 0 {
+		page.NextCursor = page.Messages[len(page.Messages)-1].MessageID
+	}
+	return page, nil
+}
+
+// ExportOption ExportMarkdown 的可选配置
+type ExportOption func(*exportConfig)
+
+type exportConfig struct {
+	thinking bool
+}
+
+// WithExportThinking 在导出的 Markdown 中包含思考过程，默认不包含
+func WithExportThinking() ExportOption {
+	return func(c *exportConfig) {
+		c.thinking = true
+	}
+}
+
+// ExportMarkdown 获取会话历史并以 Markdown 格式写入 w
+// 每条消息以 "## User" 或 "## Assistant" 作为标题，思考过程放在 ```thinking 代码块中
+func (api *DeepSeekAPI) ExportMarkdown(ctx context.Context, chatSessionID string, w io.Writer, opts ...ExportOption) error {
+	var cfg exportConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	history, err := api.FetchMessageHistory(ctx, chatSessionID)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	if history.Title != "" {
+		fmt.Fprintf(&sb, "# %s\n\n", history.Title)
+	}
+
+	for _, msg := range history.Messages {
+		role := "User"
+		if msg.Role == RoleAssistant {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", role)
+
+		if cfg.thinking && msg.ThinkingContent != "" {
+			fmt.Fprintf(&sb, "```thinking\n%s\n```\n\n", strings.TrimRight(msg.ThinkingContent, "\n"))
+		}
+		fmt.Fprintf(&sb, "%s\n\n", strings.TrimRight(msg.Content, "\n"))
+	}
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package dsk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+// recordingMetrics 记录 OnRequest 收到的接口名
+type recordingMetrics struct {
+	mu        sync.Mutex
+	endpoints []string
+}
+
+func (m *recordingMetrics) OnRequest(endpoint string, dur time.Duration, status int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoints = append(m.endpoints, endpoint)
+}
+
+func (m *recordingMetrics) OnPoWSolve(difficulty int, dur time.Duration) {}
+
+func (m *recordingMetrics) OnChunk() {}
+
+func TestFetchMessageHistoryOmitsQueryFromLabels(t *testing.T) {
+	const sessionID = "secret-session-id"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"code":404,"msg":"not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	metrics := &recordingMetrics{}
+	api := newTestClient(t, srv, dsk.WithMetrics(metrics))
+	_, err := api.FetchMessageHistoryPage(context.Background(), sessionID, "cursor-1", 20)
+
+	var apiErr *dsk.APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("FetchMessageHistoryPage error = %v, want *dsk.APIError", err)
+	}
+	if apiErr.Endpoint != "/chat/history_messages" {
+		t.Fatalf("APIError.Endpoint = %q, want the path without the query", apiErr.Endpoint)
+	}
+	if strings.Contains(err.Error(), sessionID) {
+		t.Fatalf("error %q leaks the session ID", err)
+	}
+	if len(metrics.endpoints) != 1 || metrics.endpoints[0] != "/chat/history_messages" {
+		t.Fatalf("metrics endpoints = %q, want one label without the query", metrics.endpoints)
+	}
+}
+
+// historyServer 返回固定的会话历史 biz_data，并记录请求的查询参数
+func historyServer(t *testing.T, bizData string) (*httptest.Server, func() url.Values) {
+	t.Helper()
+	var (
+		mu    sync.Mutex
+		query url.Values
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/chat/history_messages" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		query = r.URL.Query()
+		mu.Unlock()
+		io.WriteString(w, `{"code":0,"data":{"biz_code":0,"biz_data":`+bizData+`}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() url.Values {
+		mu.Lock()
+		defer mu.Unlock()
+		return query
+	}
+}
+
+func TestFetchMessageHistoryPageQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		cursor    string
+		limit     int
+		wantQuery url.Values
+	}{
+		{"first page", "", 0, url.Values{"chat_session_id": {"session-1"}}},
+		{"limit only", "", 20, url.Values{"chat_session_id": {"session-1"}, "limit": {"20"}}},
+		{"opaque cursor", "a b/c+d=&e", 50, url.Values{"chat_session_id": {"session-1"}, "cursor": {"a b/c+d=&e"}, "limit": {"50"}}},
+		{"negative limit", "7", -1, url.Values{"chat_session_id": {"session-1"}, "cursor": {"7"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, query := historyServer(t, `{"chat_messages":[]}`)
+			if _, err := newTestClient(t, srv).FetchMessageHistoryPage(context.Background(), "session-1", tt.cursor, tt.limit); err != nil {
+				t.Fatalf("FetchMessageHistoryPage: %v", err)
+			}
+			if got := query(); !reflect.DeepEqual(got, tt.wantQuery) {
+				t.Fatalf("query = %v, want %v", got, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestFetchMessageHistoryPage(t *testing.T) {
+	const messages = `"chat_messages":[
+		{"message_id":1,"role":"USER","content":"hi"},
+		{"message_id":2,"parent_id":1,"role":"ASSISTANT","content":"hello"}]`
+	tests := []struct {
+		name       string
+		bizData    string
+		wantCursor string
+	}{
+		{"string cursor", `{` + messages + `,"next_cursor":"eyJpZCI6Mn0=","has_more":true}`, "eyJpZCI6Mn0="},
+		{"numeric cursor", `{` + messages + `,"next_cursor":2,"has_more":true}`, "2"},
+		{"has more without cursor", `{` + messages + `,"has_more":true}`, "2"},
+		{"null cursor with has more", `{` + messages + `,"next_cursor":null,"has_more":true}`, "2"},
+		{"last page", `{` + messages + `,"next_cursor":null,"has_more":false}`, ""},
+		{"no pagination fields", `{` + messages + `}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := historyServer(t, tt.bizData)
+			page, err := newTestClient(t, srv).FetchMessageHistoryPage(context.Background(), "session-1", "", 2)
+			if err != nil {
+				t.Fatalf("FetchMessageHistoryPage: %v", err)
+			}
+			if page.NextCursor != tt.wantCursor {
+				t.Fatalf("NextCursor = %q, want %q", page.NextCursor, tt.wantCursor)
+			}
+			if len(page.Messages) != 2 || page.Messages[1].MessageID != "2" || page.Messages[1].Role != dsk.RoleAssistant {
+				t.Fatalf("Messages = %+v", page.Messages)
+			}
+		})
+	}
+}
+
+func TestFetchMessageHistoryPageInvalidCursor(t *testing.T) {
+	srv, _ := historyServer(t, `{"chat_messages":[],"next_cursor":{"id":2}}`)
+	if _, err := newTestClient(t, srv).FetchMessageHistoryPage(context.Background(), "session-1", "", 0); err == nil {
+		t.Fatal("FetchMessageHistoryPage succeeded with an object cursor")
+	}
+}
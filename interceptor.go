@@ -0,0 +1,11 @@
+package dsk
+
+import "net/http"
+
+// RequestInterceptor 在每个请求（包括 PoW 挑战和流式请求）发送前调用
+// 可以修改请求头，返回错误时请求不会被发送
+type RequestInterceptor func(*http.Request) error
+
+// ResponseInterceptor 在收到每个响应后、读取响应体之前调用
+// 返回错误时响应体会被关闭，调用方收到该错误
+type ResponseInterceptor func(*http.Response) error
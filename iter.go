@@ -0,0 +1,37 @@
+//go:build go1.23
+
+package dsk
+
+import (
+	"context"
+	"iter"
+)
+
+// Stream 发送消息并以迭代器返回流式响应，可以直接用于 for range：
+//
+//	for chunk, err := range api.Stream(ctx, chatID, prompt, dsk.WithThinking()) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Print(chunk.Content)
+//	}
+//
+// 正常结束时不会产生错误；出错时最后一次迭代的 err 不为 nil（chunk 为零值）。
+// 提前 break 会取消底层请求
+func (api *DeepSeekAPI) Stream(ctx context.Context, chatSessionID, prompt string, opts ...CompletionOption) iter.Seq2[Chunk, error] {
+	return func(yield func(Chunk, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		chunkChan, errChan := api.Complete(ctx, chatSessionID, prompt, opts...)
+		for chunk := range chunkChan {
+			if !yield(chunk, nil) {
+				return
+			}
+		}
+
+		if err := <-errChan; err != nil {
+			yield(Chunk{}, err)
+		}
+	}
+}
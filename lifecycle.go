@@ -0,0 +1,65 @@
+package dsk
+
+import "context"
+
+// track 登记一个进行中的请求，返回的 ctx 会在 Close 等待超时后被取消
+// 请求结束时必须调用 done；客户端已关闭时返回 ErrClosed
+func (api *DeepSeekAPI) track(ctx context.Context) (context.Context, func(), error) {
+	api.closeMu.Lock()
+	defer api.closeMu.Unlock()
+
+	if api.closed {
+		return nil, nil, ErrClosed
+	}
+	api.inflight.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(api.shutdown, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+		api.inflight.Done()
+	}, nil
+}
+
+// Close 拒绝新的请求，等待进行中的请求结束后释放 WASM 运行时
+// ctx 到期时取消所有进行中的请求并返回 ctx.Err()，运行时会在这些请求退出后再释放，
+// 因此不会出现流仍在使用已关闭运行时的情况；重复调用 Close 直接返回 nil
+// 通过 WithPoWSolver 传入的求解器由调用方自行管理
+func (api *DeepSeekAPI) Close(ctx context.Context) error {
+	api.closeMu.Lock()
+	if api.closed {
+		api.closeMu.Unlock()
+		return nil
+	}
+	api.closed = true
+	api.closeMu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		api.inflight.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		api.cancel()
+		return api.closePoW()
+	case <-ctx.Done():
+		api.log.Warnf("Close deadline exceeded, cancelling in-flight requests")
+		api.cancel()
+		go func() {
+			<-drained
+			api.closePoW()
+		}()
+		return ctx.Err()
+	}
+}
+
+// closePoW 释放客户端自己创建的 WASM 求解器
+func (api *DeepSeekAPI) closePoW() error {
+	if api.ownedPoW != nil {
+		return api.ownedPoW.Close()
+	}
+	return nil
+}
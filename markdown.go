@@ -0,0 +1,93 @@
+package dsk
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingPattern    = regexp.MustCompile(`^\s{0,3}#{1,6}\s+`)
+	quotePattern      = regexp.MustCompile(`^\s{0,3}>\s?`)
+	starListPattern   = regexp.MustCompile(`^(\s*)[*+]\s+`)
+	linkPattern       = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+	inlineCodePattern = regexp.MustCompile("`([^`]+)`")
+	strongPattern     = regexp.MustCompile(`(\*\*|__|~~)(\S(?:.*?\S)?)(\*\*|__|~~)`)
+	starEmPattern     = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	underEmPattern    = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_([^\w]|$)`)
+)
+
+// StripMarkdown 把 Markdown 文本转换为纯文本
+// 去掉代码块标记、标题、引用、强调和链接语法，代码块内的内容原样保留
+func StripMarkdown(s string) string {
+	var m MarkdownStripper
+	return m.Write(s) + m.Flush()
+}
+
+// MarkdownStripper 增量地把流式 Markdown 转换为纯文本，适合逐个 chunk 处理
+// Markdown 语法可能跨越 chunk 边界，因此按行处理：Write 只返回已经完整的行，
+// 未结束的行保留到下一次 Write，流结束后需要调用 Flush 取出最后一行
+// 零值即可使用，不能在多个 goroutine 中同时使用
+type MarkdownStripper struct {
+	pending strings.Builder
+	inCode  bool
+}
+
+// Write 写入一段内容，返回其中已经完整的行转换后的纯文本
+func (m *MarkdownStripper) Write(chunk string) string {
+	m.pending.WriteString(chunk)
+	buffered := m.pending.String()
+
+	last := strings.LastIndexByte(buffered, '\n')
+	if last < 0 {
+		return ""
+	}
+
+	m.pending.Reset()
+	m.pending.WriteString(buffered[last+1:])
+
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(buffered[:last+1], "\n") {
+		if line == "" {
+			continue
+		}
+		text, keep := m.stripLine(strings.TrimSuffix(line, "\n"))
+		if keep {
+			sb.WriteString(text)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// Flush 返回缓冲中最后一行（没有换行符结尾）转换后的纯文本
+func (m *MarkdownStripper) Flush() string {
+	line := m.pending.String()
+	m.pending.Reset()
+	if line == "" {
+		return ""
+	}
+
+	text, _ := m.stripLine(line)
+	return text
+}
+
+// stripLine 转换一行内容，keep 为 false 表示该行是代码块标记，需要整行去掉
+func (m *MarkdownStripper) stripLine(line string) (text string, keep bool) {
+	if strings.HasPrefix(strings.TrimSpace(line), "```") {
+		m.inCode = !m.inCode
+		return "", false
+	}
+	if m.inCode {
+		return line, true
+	}
+
+	line = headingPattern.ReplaceAllString(line, "")
+	line = quotePattern.ReplaceAllString(line, "")
+	line = starListPattern.ReplaceAllString(line, "${1}- ")
+	line = linkPattern.ReplaceAllString(line, "$1")
+	line = inlineCodePattern.ReplaceAllString(line, "$1")
+	line = strongPattern.ReplaceAllString(line, "$2")
+	line = starEmPattern.ReplaceAllString(line, "$1")
+	line = underEmPattern.ReplaceAllString(line, "$1$2$3")
+	return line, true
+}
@@ -0,0 +1,61 @@
+package dsk
+
+import (
+	"sync"
+	"time"
+)
+
+// MetricsHook 指标回调接口，可以用来向 Prometheus 等系统导出请求延迟和计数
+// 回调在请求所在的 goroutine 中同步执行，实现应当尽快返回并且是并发安全的
+type MetricsHook interface {
+	// OnRequest 每个 HTTP 请求收到响应头（或失败）后调用，请求失败时 status 为 0
+	OnRequest(endpoint string, dur time.Duration, status int)
+	// OnPoWSolve 每次 PoW 求解成功后调用
+	OnPoWSolve(difficulty int, dur time.Duration)
+	// OnChunk 流式响应每发送一个 Chunk 调用一次
+	OnChunk()
+}
+
+// noopMetrics 默认的空实现
+type noopMetrics struct{}
+
+func (noopMetrics) OnRequest(string, time.Duration, int) {}
+func (noopMetrics) OnPoWSolve(int, time.Duration)        {}
+func (noopMetrics) OnChunk()                             {}
+
+// PoWStats PoW 求解的统计快照，通过 DeepSeekAPI.PoWStats 获取
+type PoWStats struct {
+	LastDifficulty int           // 最近一次求解的难度，小数难度四舍五入
+	LastDuration   time.Duration // 最近一次求解的耗时
+	TotalSolves    int64         // 求解成功的总次数，不包括命中缓存的请求
+	TotalDuration  time.Duration // 所有求解的总耗时
+}
+
+// powStats 并发安全的 PoW 统计
+type powStats struct {
+	mu    sync.Mutex
+	stats PoWStats
+}
+
+// record 记录一次成功的求解
+func (s *powStats) record(difficulty int, dur time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.LastDifficulty = difficulty
+	s.stats.LastDuration = dur
+	s.stats.TotalSolves++
+	s.stats.TotalDuration += dur
+}
+
+// snapshot 返回当前统计的副本
+func (s *powStats) snapshot() PoWStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// PoWStats 返回 PoW 求解的统计快照，适合不需要接入 MetricsHook、只想快速查看求解耗时的场景
+func (api *DeepSeekAPI) PoWStats() PoWStats {
+	return api.powStats.snapshot()
+}
@@ -0,0 +1,51 @@
+package dsk
+
+import (
+	"context"
+)
+
+// Model 账号可用的模型及其能力
+type Model struct {
+	ID       string // 模型类型，发送请求时使用的 id
+	Name     string // 展示名称
+	Enabled  bool   // 当前账号是否可用
+	Thinking bool   // 是否支持思考过程（thinking_enabled）
+	Search   bool   // 是否支持联网搜索（search_enabled）
+	File     bool   // 是否支持上传文件
+}
+
+// FetchModels 获取当前账号可用的模型及其能力，可用于决定是否展示思考和搜索开关
+// 网页版没有专门的模型列表接口，这里解析客户端配置接口中的模型配置，字段以服务端实际返回为准
+func (api *DeepSeekAPI) FetchModels(ctx context.Context) ([]Model, error) {
+	resp, err := api.makeRequest(ctx, "GET", "/client/settings?scope=model", nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings struct {
+		ModelConfigs []struct {
+			ModelType       string `json:"model_type"`
+			DisplayName     string `json:"display_name"`
+			Enabled         bool   `json:"enabled"`
+			ThinkingEnabled bool   `json:"thinking_enabled"`
+			SearchEnabled   bool   `json:"search_enabled"`
+			FileEnabled     bool   `json:"file_enabled"`
+		} `json:"model_configs"`
+	}
+	if err := decodeBizData(resp, &settings); err != nil {
+		return nil, err
+	}
+
+	models := make([]Model, 0, len(settings.ModelConfigs))
+	for _, cfg := range settings.ModelConfigs {
+		models = append(models, Model{
+			ID:       cfg.ModelType,
+			Name:     cfg.DisplayName,
+			Enabled:  cfg.Enabled,
+			Thinking: cfg.ThinkingEnabled,
+			Search:   cfg.SearchEnabled,
+			File:     cfg.FileEnabled,
+		})
+	}
+	return models, nil
+}
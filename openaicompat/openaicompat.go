@@ -0,0 +1,239 @@
+// Package openaicompat 提供 OpenAI Chat Completions 风格的请求和响应，
+// 方便把基于 OpenAI SDK 编写的代码迁移到 DeepSeek 网页版接口
+package openaicompat
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+const (
+	// ModelChat 对应普通对话
+	ModelChat = "deepseek-chat"
+	// ModelReasoner 对应开启思考过程的对话
+	ModelReasoner = "deepseek-reasoner"
+)
+
+// OpenAIMessage OpenAI 格式的消息
+type OpenAIMessage struct {
+	Role             string `json:"role"` // "system"、"user" 或 "assistant"
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"` // 思考过程，与 DeepSeek 官方 API 字段一致
+}
+
+// OpenAIRequest OpenAI 格式的对话请求，只包含能映射到 DeepSeek 的字段
+type OpenAIRequest struct {
+	Model    string          `json:"model"`
+	Messages []OpenAIMessage `json:"messages"`
+	Stream   bool            `json:"stream,omitempty"`
+
+	// 以下为扩展参数，不属于 OpenAI 标准字段
+	// Model 为 ModelReasoner 时自动开启思考过程
+	Thinking bool `json:"thinking,omitempty"` // 开启思考过程
+	Search   bool `json:"search,omitempty"`   // 开启联网搜索
+}
+
+// OpenAIChoice 非流式响应中的一个候选结果
+type OpenAIChoice struct {
+	Index        int           `json:"index"`
+	Message      OpenAIMessage `json:"message"`
+	FinishReason string        `json:"finish_reason"`
+}
+
+// OpenAIResponse OpenAI 格式的非流式响应
+type OpenAIResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"` // 固定为 "chat.completion"
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Choices []OpenAIChoice `json:"choices"`
+}
+
+// OpenAIDelta 流式响应中的增量内容
+type OpenAIDelta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+// OpenAIStreamChoice 流式响应中的一个候选结果
+type OpenAIStreamChoice struct {
+	Index        int         `json:"index"`
+	Delta        OpenAIDelta `json:"delta"`
+	FinishReason *string     `json:"finish_reason"`
+}
+
+// OpenAIStreamResponse OpenAI 格式的流式响应数据块
+type OpenAIStreamResponse struct {
+	ID      string               `json:"id"`
+	Object  string               `json:"object"` // 固定为 "chat.completion.chunk"
+	Created int64                `json:"created"`
+	Model   string               `json:"model"`
+	Choices []OpenAIStreamChoice `json:"choices"`
+}
+
+// Client 把 OpenAI 格式的请求转换为 DeepSeek 请求
+// OpenAI 接口是无状态的，每次请求都会创建新的 DeepSeek 会话，并把 messages 合并为一条 prompt
+type Client struct {
+	api dsk.APIClient
+}
+
+// NewClient 创建兼容 OpenAI 格式的客户端，api 通常为 *dsk.DeepSeekAPI
+func NewClient(api dsk.APIClient) *Client {
+	return &Client{api: api}
+}
+
+// ChatCompletion 发送请求并等待完整响应
+// 开启了 dsk.WithStreamReconnect 时，服务端重新生成的回答会替换之前收到的部分内容
+func (c *Client) ChatCompletion(ctx context.Context, req OpenAIRequest) (OpenAIResponse, error) {
+	chatID, chunkChan, errChan, err := c.start(ctx, req)
+	if err != nil {
+		return OpenAIResponse{}, err
+	}
+	created := time.Now().Unix()
+
+	result, err := dsk.CollectResult(chunkChan, errChan)
+	if err != nil {
+		return OpenAIResponse{}, err
+	}
+
+	finishReason := "stop"
+	if result.FinishReason != "" {
+		finishReason = openAIFinishReason(result.FinishReason)
+	}
+	return OpenAIResponse{
+		ID:      "chatcmpl-" + chatID,
+		Object:  "chat.completion",
+		Created: created,
+		Model:   req.Model,
+		Choices: []OpenAIChoice{{
+			Index: 0,
+			Message: OpenAIMessage{
+				Role:             "assistant",
+				Content:          result.Text,
+				ReasoningContent: result.Thinking,
+			},
+			FinishReason: finishReason,
+		}},
+	}, nil
+}
+
+// start 创建 DeepSeek 会话并发送合并后的 prompt
+func (c *Client) start(ctx context.Context, req OpenAIRequest) (string, <-chan dsk.Chunk, <-chan error, error) {
+	prompt := buildPrompt(req.Messages)
+	if prompt == "" {
+		return "", nil, nil, fmt.Errorf("messages cannot be empty")
+	}
+
+	chatID, err := c.api.CreateChatSessionContext(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	thinking := req.Thinking || req.Model == ModelReasoner
+	chunkChan, errChan := c.api.ChatCompletionContext(ctx, chatID, prompt, nil, thinking, req.Search)
+	return chatID, chunkChan, errChan, nil
+}
+
+// ChatCompletionStream 发送请求并以 OpenAI 流式格式返回响应
+// 思考过程映射到 delta.reasoning_content，回答映射到 delta.content
+// 开启了 dsk.WithStreamReconnect 时，服务端在已经输出内容之后重新生成回答会导致请求失败，已经发送的内容无法撤回
+func (c *Client) ChatCompletionStream(ctx context.Context, req OpenAIRequest) (<-chan OpenAIStreamResponse, <-chan error) {
+	respChan := make(chan OpenAIStreamResponse, 10)
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer close(respChan)
+		defer close(errChan)
+
+		// 提前返回时取消请求，不再读取的响应不会阻塞 DeepSeek 客户端
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		chatID, chunkChan, dsErrChan, err := c.start(ctx, req)
+		if err != nil {
+			errChan <- err
+			return
+		}
+
+		id := "chatcmpl-" + chatID
+		created := time.Now().Unix()
+		send := func(delta OpenAIDelta, finishReason *string) bool {
+			select {
+			case respChan <- OpenAIStreamResponse{
+				ID:      id,
+				Object:  "chat.completion.chunk",
+				Created: created,
+				Model:   req.Model,
+				Choices: []OpenAIStreamChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+			}:
+				return true
+			case <-ctx.Done():
+				errChan <- ctx.Err()
+				return false
+			}
+		}
+
+		// 角色随第一个数据块发送，这样在收到 DeepSeek 的响应之前不会输出任何内容，
+		// 代理可以在 PoW 或请求失败时返回正确的状态码
+		role := "assistant"
+		for chunk := range chunkChan {
+			if chunk.Type == "restart" {
+				if role != "" {
+					// 还没有输出内容，直接使用重新生成的回答
+					continue
+				}
+				errChan <- fmt.Errorf("answer was restarted after reconnecting, content already sent is no longer valid")
+				return
+			}
+			delta := OpenAIDelta{Role: role}
+			if chunk.Type == "thinking" {
+				delta.ReasoningContent = chunk.Content
+			} else {
+				delta.Content = chunk.Content
+			}
+
+			var finishReason *string
+			if chunk.FinishReason != "" {
+				reason := openAIFinishReason(chunk.FinishReason)
+				finishReason = &reason
+			}
+
+			if delta.Content == "" && delta.ReasoningContent == "" && finishReason == nil {
+				continue
+			}
+			if !send(delta, finishReason) {
+				return
+			}
+			role = ""
+		}
+
+		if err := <-dsErrChan; err != nil {
+			errChan <- err
+		}
+	}()
+
+	return respChan, errChan
+}
+
+// openAIFinishReason 把 DeepSeek 的完成原因映射为 OpenAI 的取值
+// dsk.FinishReasonEOF 是客户端在连接提前关闭时补发的，OpenAI 没有对应的值，按 "stop" 处理，
+// 避免按枚举解析 finish_reason 的 OpenAI SDK 报错
+func openAIFinishReason(reason string) string {
+	if reason == dsk.FinishReasonEOF {
+		return "stop"
+	}
+	return reason
+}
+
+// buildPrompt 把 OpenAI 的多轮消息合并为 DeepSeek 的单条 prompt，规则见 dsk.BuildPrompt
+func buildPrompt(messages []OpenAIMessage) string {
+	dsMessages := make([]dsk.Message, 0, len(messages))
+	for _, msg := range messages {
+		dsMessages = append(dsMessages, dsk.Message{Role: msg.Role, Content: msg.Content})
+	}
+	return dsk.BuildPrompt(dsMessages)
+}
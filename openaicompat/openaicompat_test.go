@@ -0,0 +1,147 @@
+package openaicompat_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+	"github.com/minchieh-fay/dsk/openaicompat"
+)
+
+func TestChatCompletionStreamEndsWithoutFinishReason(t *testing.T) {
+	// 连接在发送 finish_reason 和 [DONE] 之前关闭
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{dsktest.TextEvent("partial")},
+	})
+	defer srv.Close()
+
+	api, err := dsktest.NewClient(srv, dsk.WithPoWSolver(dsktest.StubSolver{}), dsk.WithLogLevel(dsk.LevelOff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close(context.Background())
+	client := openaicompat.NewClient(api)
+	req := openaicompat.OpenAIRequest{
+		Model:    openaicompat.ModelChat,
+		Messages: []openaicompat.OpenAIMessage{{Role: "user", Content: "hi"}},
+	}
+
+	respChan, errChan := client.ChatCompletionStream(context.Background(), req)
+	var last openaicompat.OpenAIStreamResponse
+	for resp := range respChan {
+		last = resp
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("ChatCompletionStream: %v", err)
+	}
+	if reason := last.Choices[0].FinishReason; reason == nil || *reason != "stop" {
+		t.Fatalf("last finish_reason = %v, want stop", reason)
+	}
+
+	resp, err := client.ChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if choice := resp.Choices[0]; choice.FinishReason != "stop" || choice.Message.Content != "partial" {
+		t.Fatalf("choice = %+v, want content %q and finish_reason stop", choice, "partial")
+	}
+}
+
+// sseWithIDs 把事件编号为 firstID、firstID+1……后按 SSE 格式拼接
+func sseWithIDs(firstID int, events ...string) string {
+	var sb strings.Builder
+	for i, event := range events {
+		fmt.Fprintf(&sb, "id: %d\ndata: %s\n\n", firstID+i, event)
+	}
+	return sb.String()
+}
+
+// newReconnectClient 返回开启了重连的客户端，服务端第一次回答发送 "Hello" 后中断连接，重连后发送 second
+func newReconnectClient(t *testing.T, second string) *openaicompat.Client {
+	t.Helper()
+	streams := []http.Handler{
+		dsktest.NewHandler(dsktest.MockOptions{RawStream: sseWithIDs(1, dsktest.TextEvent("Hel"), dsktest.TextEvent("lo"))}),
+		dsktest.NewHandler(dsktest.MockOptions{RawStream: second}),
+	}
+	var completions atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completion") {
+			streams[0].ServeHTTP(w, r)
+			return
+		}
+		n := completions.Add(1)
+		streams[n-1].ServeHTTP(w, r)
+		if n == 1 {
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	api, err := dsktest.NewClient(srv,
+		dsk.WithPoWSolver(dsktest.StubSolver{}),
+		dsk.WithLogLevel(dsk.LevelOff),
+		dsk.WithStreamReconnect(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { api.Close(context.Background()) })
+	return openaicompat.NewClient(api)
+}
+
+func TestChatCompletionReconnect(t *testing.T) {
+	tests := []struct {
+		name   string
+		second string
+		want   string
+		// wantStreamErr 服务端从头重新生成时，流式响应已经发送的内容无法撤回，应当失败
+		wantStreamErr bool
+	}{
+		{"resumed", sseWithIDs(3, dsktest.TextEvent(" world"), dsktest.StopEvent()), "Hello world", false},
+		{"restarted", sseWithIDs(1, dsktest.TextEvent("Hi"), dsktest.TextEvent(" there"), dsktest.StopEvent()), "Hi there", true},
+	}
+	req := openaicompat.OpenAIRequest{
+		Model:    openaicompat.ModelChat,
+		Messages: []openaicompat.OpenAIMessage{{Role: "user", Content: "hi"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := newReconnectClient(t, tt.second).ChatCompletion(context.Background(), req)
+			if err != nil {
+				t.Fatalf("ChatCompletion: %v", err)
+			}
+			if got := resp.Choices[0].Message.Content; got != tt.want {
+				t.Fatalf("ChatCompletion content = %q, want %q", got, tt.want)
+			}
+
+			respChan, errChan := newReconnectClient(t, tt.second).ChatCompletionStream(context.Background(), req)
+			var sb strings.Builder
+			for resp := range respChan {
+				sb.WriteString(resp.Choices[0].Delta.Content)
+			}
+			err = <-errChan
+			if tt.wantStreamErr {
+				if err == nil {
+					t.Fatalf("ChatCompletionStream succeeded with %q after the answer was restarted", sb.String())
+				}
+				if got := sb.String(); got != "Hello" {
+					t.Fatalf("streamed %q before the restart, want %q", got, "Hello")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ChatCompletionStream: %v", err)
+			}
+			if got := sb.String(); got != tt.want {
+				t.Fatalf("streamed %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/minchieh-fay/dsk"
+)
+
+// NewOpenAIProxy 创建兼容 OpenAI 接口的 HTTP 处理器，提供 POST /v1/chat/completions
+// 可以把 LangChain、llm CLI 等支持 OpenAI 接口的工具指向本地地址来使用 DeepSeek；
+// stream 为 true 时以 SSE 的 data: 格式输出，并以 data: [DONE] 结束
+func NewOpenAIProxy(api dsk.APIClient) http.Handler {
+	client := NewClient(api)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "invalid_request_error", "method not allowed")
+			return
+		}
+
+		var req OpenAIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("invalid request body: %v", err))
+			return
+		}
+
+		if req.Stream {
+			serveStream(w, r, client, req)
+			return
+		}
+
+		resp, err := client.ChatCompletion(r.Context(), req)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	return mux
+}
+
+// serveStream 以 OpenAI 的 SSE 格式输出流式响应
+func serveStream(w http.ResponseWriter, r *http.Request, client *Client, req OpenAIRequest) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "server_error", "streaming is not supported by the server")
+		return
+	}
+
+	respChan, errChan := client.ChatCompletionStream(r.Context(), req)
+
+	// 在写出响应头之前先等第一个数据块，这样会话创建或 PoW 失败时仍然可以返回正确的状态码
+	first, ok := <-respChan
+	if !ok {
+		if err := <-errChan; err != nil {
+			writeAPIError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	writeEvent := func(v interface{}) {
+		data, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+
+	if ok {
+		writeEvent(first)
+		for resp := range respChan {
+			writeEvent(resp)
+		}
+	}
+
+	// 响应头已经写出，流中途的错误只能以 error 事件的形式告知客户端
+	if err := <-errChan; err != nil {
+		writeEvent(errorBody("server_error", err.Error()))
+	}
+
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// writeAPIError 把 DeepSeek 的错误映射为 OpenAI 风格的状态码和错误类型
+func writeAPIError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dsk.ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, "authentication_error", err.Error())
+	case errors.Is(err, dsk.ErrRateLimited):
+		writeError(w, http.StatusTooManyRequests, "rate_limit_error", err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, "server_error", err.Error())
+	}
+}
+
+// writeError 输出 OpenAI 格式的错误响应
+func writeError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorBody(errType, message))
+}
+
+// errorBody 构造 OpenAI 格式的错误响应体
+func errorBody(errType, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": message,
+			"type":    errType,
+		},
+	}
+}
@@ -0,0 +1,248 @@
+package dsk
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Option 配置 DeepSeekAPI 客户端的可选项
+type Option func(*DeepSeekAPI)
+
+// WithWazeroConfig 设置 PoW 求解器使用的 wazero 运行时配置
+// 默认使用 wazero.NewRuntimeConfig()；在编译器不可用的平台上可以传入
+// wazero.NewRuntimeConfigInterpreter() 强制使用解释器，
+// 也可以传入 wazero.NewRuntimeConfigCompiler() 显式使用编译器
+// 传入的配置总是会开启 WithCloseOnContextDone，以便 ctx 取消时中断正在进行的求解
+func WithWazeroConfig(cfg wazero.RuntimeConfig) Option {
+	return func(api *DeepSeekAPI) {
+		api.wazeroConfig = cfg
+	}
+}
+
+// WithLogger 设置客户端使用的日志实现
+// 默认只在 EnableDebug 为 true 时输出到 stderr（可通过 SetDebugWriter 修改）
+func WithLogger(l Logger) Option {
+	return func(api *DeepSeekAPI) {
+		if l != nil {
+			api.log.Logger = l
+		}
+	}
+}
+
+// WithLogLevel 设置日志级别，默认为 LevelDebug
+// LevelDebug 输出请求生命周期，LevelTrace 额外输出 SSE 流中的每一行，适合排查流式响应问题
+func WithLogLevel(lvl Level) Option {
+	return func(api *DeepSeekAPI) {
+		api.log.level = lvl
+	}
+}
+
+// WithMetrics 设置指标回调，默认不记录任何指标
+func WithMetrics(h MetricsHook) Option {
+	return func(api *DeepSeekAPI) {
+		if h != nil {
+			api.metrics = h
+		}
+	}
+}
+
+// WithRequestInterceptor 添加请求拦截器，多次调用时按添加顺序执行
+func WithRequestInterceptor(fns ...RequestInterceptor) Option {
+	return func(api *DeepSeekAPI) {
+		api.requestInterceptors = append(api.requestInterceptors, fns...)
+	}
+}
+
+// WithResponseInterceptor 添加响应拦截器，多次调用时按添加顺序执行
+func WithResponseInterceptor(fns ...ResponseInterceptor) Option {
+	return func(api *DeepSeekAPI) {
+		api.responseInterceptors = append(api.responseInterceptors, fns...)
+	}
+}
+
+// WithRequestIDFunc 为每个请求（PoW 挑战、会话、对话）生成 x-request-id 请求头，
+// 该 id 会出现在调试日志和返回的 APIError 中，便于和服务端日志关联。默认不设置
+func WithRequestIDFunc(fn func() string) Option {
+	return func(api *DeepSeekAPI) {
+		api.requestIDFunc = fn
+	}
+}
+
+// WithTokenProvider 设置 token 提供函数，用于 token 会在外部轮换的长期运行服务
+// 客户端没有 token 时会调用它获取，请求返回 401 时也会调用一次并重发请求；
+// 请求体无法重放的请求（例如 UploadFile）只刷新 token，仍然返回 401 错误，由调用方决定是否重新上传；
+// 设置后 NewDeepSeekAPI 的 authToken 参数可以为空
+func WithTokenProvider(fn func(ctx context.Context) (string, error)) Option {
+	return func(api *DeepSeekAPI) {
+		api.tokenProvider = fn
+	}
+}
+
+// WithBaseURL 设置 API 地址，默认为 BaseURL，主要用于连接测试服务器或反向代理
+func WithBaseURL(baseURL string) Option {
+	return func(api *DeepSeekAPI) {
+		if baseURL != "" {
+			api.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+// WithPoWSolver 使用自定义的 PoW 求解器代替嵌入的 WASM 求解器
+// 设置后不会创建 wazero 运行时，WithWazeroConfig、WithPoWAlgorithm 和自定义 WASM 路径都不再生效，
+// 需要按算法分派时可以传入 *PoWRegistry；求解器的生命周期由调用方管理，Close 不会关闭它
+func WithPoWSolver(s PoWSolver) Option {
+	return func(api *DeepSeekAPI) {
+		api.powSolver = s
+	}
+}
+
+// WithStreamIdleTimeout 设置流式响应的空闲超时，默认不限制
+// 连续 d 时间没有收到任何字节时中断流，errChan 中返回的错误满足 errors.Is(err, ErrStreamIdle)；
+// 每次收到数据都会重新计时（滑动窗口，而不是整个响应的截止时间），因此持续输出的长响应不会触发超时，
+// 可以防止网络静默断开时 goroutine 一直阻塞；服务端发送的心跳（": keep-alive" 注释行）同样会重新计时
+// 需要限制整个回答的总时长时使用 WithCompletionTimeout
+func WithStreamIdleTimeout(d time.Duration) Option {
+	return func(api *DeepSeekAPI) {
+		api.streamIdleTimeout = d
+	}
+}
+
+// WithStreamReconnect 流式响应在结束之前中断（连接出错或没有结束信号就关闭）时最多自动重连 n 次，默认不重连
+// 重连会重新求解 PoW 并通过 Last-Event-ID 请求头发送最后收到的事件 ID，服务端支持时从该事件之后继续发送；
+// 服务端忽略请求头、从头重新生成回答时，会先发送一个 Type 为 "restart" 的数据块，之前收到的内容应当丢弃，
+// CollectResponse、CollectMessage 和 AggregateThinking 会自动处理，CompletionReader 已经读出内容时返回错误。
+// 空闲超时和 ctx 取消不会触发重连，重连次数见 StreamStats.Reconnects
+func WithStreamReconnect(n int) Option {
+	return func(api *DeepSeekAPI) {
+		api.streamReconnects = n
+	}
+}
+
+// WithCompletionTimeout 限制每次对话请求的总时长（包括 PoW、整个流式响应和 WithStreamReconnect 的重连），默认不限制
+// 内置的求解器在超时后会中断正在进行的 PoW，WithPoWSolver 设置的求解器需要自行响应 ctx。与 WithStreamIdleTimeout 不同，即使服务端一直在输出，超过 d 后也会取消请求，用于防止失控的超长回答；
+// 两者可以同时设置。超时后 errChan 中返回的错误满足 errors.Is(err, ErrCompletionTimeout) 和
+// errors.Is(err, context.DeadlineExceeded)，超时前收到的数据块仍会先从 chunkChan 读出，
+// CollectResponse 等函数会同时返回已经收到的部分回答
+func WithCompletionTimeout(d time.Duration) Option {
+	return func(api *DeepSeekAPI) {
+		api.completionTimeout = d
+	}
+}
+
+// WithMaxPromptTokens 发送前按 EstimateTokens 的估算把 prompt 裁剪到 maxTokens 以内，默认不裁剪
+// side 指定裁剪位置，见 TruncatePrompt；适合在发送长文档前限制上下文长度
+func WithMaxPromptTokens(maxTokens int, side TruncateSide) Option {
+	return func(api *DeepSeekAPI) {
+		api.maxPromptTokens = maxTokens
+		api.truncateSide = side
+	}
+}
+
+// WithSystemPrompt 设置系统指令，用于给助手设定固定的角色或行为
+// 网页版接口没有单独的 system 字段，系统指令会以 "System: " 前缀加在会话第一条消息之前
+// （即 parentMessageID 为 nil 的请求，格式同 BuildPrompt）；后续轮次通过 parentMessageID
+// 继续对话时模型已经在会话历史中看到过该指令，不会重复发送
+func WithSystemPrompt(s string) Option {
+	return func(api *DeepSeekAPI) {
+		api.systemPrompt = s
+	}
+}
+
+// WithMaxLineSize 限制流式响应中单行的最大字节数，默认不限制
+// 一个很长的思考过程可能在一行 data: 中发送，因此默认允许任意长度；
+// 需要防止异常响应占用过多内存时可以设置上限，超过时 errChan 中返回的错误满足 errors.Is(err, ErrLineTooLong)
+func WithMaxLineSize(n int) Option {
+	return func(api *DeepSeekAPI) {
+		api.maxLineSize = n
+	}
+}
+
+// WithDefaultThinking 设置对话请求默认是否启用思考过程，单次请求可以用 WithThinking 或 WithoutThinking 覆盖
+// 作用于 Complete、EditMessage、RegenerateMessage 和 Conversation；ChatCompletion 的参数是明确的设置，不受影响
+func WithDefaultThinking(enabled bool) Option {
+	return func(api *DeepSeekAPI) {
+		api.defaultThinking = enabled
+	}
+}
+
+// WithDefaultSearch 设置对话请求默认是否启用网络搜索，单次请求可以用 WithSearch 或 WithoutSearch 覆盖，
+// 作用范围与 WithDefaultThinking 相同
+func WithDefaultSearch(enabled bool) Option {
+	return func(api *DeepSeekAPI) {
+		api.defaultSearch = enabled
+	}
+}
+
+// WithMaxParseErrors 限制流式响应中连续无法解析为 JSON 的事件数，默认不限制
+// 单个无法解析的事件会被记录后跳过；连续 n 个事件解析失败时认为响应已经异常，
+// 结束流并在 errChan 中返回满足 errors.Is(err, ErrTooManyParseErrors) 的错误。成功解析一个事件后重新计数
+func WithMaxParseErrors(n int) Option {
+	return func(api *DeepSeekAPI) {
+		api.maxParseErrors = n
+	}
+}
+
+// WithTokenSelector 设置多 token 客户端选择 token 的策略，通常与 NewDeepSeekAPIWithTokens 一起使用
+// 设置后 authToken 和 WithTokenProvider 都不再生效
+func WithTokenSelector(s TokenSelector) Option {
+	return func(api *DeepSeekAPI) {
+		api.tokenSelector = s
+	}
+}
+
+// WithExpectedWASMHash 要求加载的 WASM 文件的 SHA-256 与 hash（十六进制）一致，否则创建客户端失败
+// 用于 NewDeepSeekAPIWithCustomWASM，防止误用或被篡改的 WASM 文件；通过 WithPoWSolver 传入求解器时不生效
+func WithExpectedWASMHash(hash string) Option {
+	return func(api *DeepSeekAPI) {
+		api.expectedWASMHash = hash
+	}
+}
+
+// WithPoWAlgorithm 为 algorithm 注册额外的 PoW 求解器，与嵌入的 AlgorithmDeepSeekHashV1 求解器并存
+// 客户端按挑战的 algorithm 字段选择求解器，DeepSeek 推出新算法时无需替换整个求解器；
+// 注册 AlgorithmDeepSeekHashV1 会替换嵌入的求解器
+func WithPoWAlgorithm(algorithm string, s PoWSolver) Option {
+	return func(api *DeepSeekAPI) {
+		if api.powAlgorithms == nil {
+			api.powAlgorithms = make(map[string]PoWSolver)
+		}
+		api.powAlgorithms[algorithm] = s
+	}
+}
+
+// WithPoWConcurrency 设置嵌入的 WASM 求解器的实例数，默认为 1
+// 单个实例同一时间只能求解一个挑战，BatchComplete 等并发请求时 PoW 求解会排队；
+// 设置为 n 后最多同时求解 n 个挑战，代价是 n 份 WASM 内存。通过 WithPoWSolver 传入求解器时不生效
+func WithPoWConcurrency(n int) Option {
+	return func(api *DeepSeekAPI) {
+		api.powInstances = n
+	}
+}
+
+// WithAutoSession 让 ChatCompletion 和 Complete 在会话 ID 为空时自动创建新会话，适合一次性的脚本
+// 新会话的 ID 通过响应中第一个 Type 为 "meta" 的 Chunk 的 ChatSessionID 字段返回，
+// 继续对话时需要使用该 ID；默认不开启，空会话 ID 会原样发送给服务端
+func WithAutoSession() Option {
+	return func(api *DeepSeekAPI) {
+		api.autoSession = true
+	}
+}
+
+// WithMaxUploadSize 设置 UploadFile 允许的最大文件字节数，默认为 DefaultMaxUploadSize，小于等于 0 时不限制
+// 超过限制时 UploadFile 在发送文件内容之前返回 ErrFileTooLarge
+func WithMaxUploadSize(n int64) Option {
+	return func(api *DeepSeekAPI) {
+		api.maxUploadSize = n
+	}
+}
+
+// WithAllowedUploadTypes 设置 UploadFile 允许的 MIME 类型，替换默认的 DefaultUploadTypes
+// 类型可以写成 "text/*" 匹配一类；不传参数时不检查文件类型
+func WithAllowedUploadTypes(types ...string) Option {
+	return func(api *DeepSeekAPI) {
+		api.allowedUploadTypes = types
+	}
+}
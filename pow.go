@@ -2,15 +2,23 @@ package dsk
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
@@ -20,6 +28,9 @@ import (
 //go:embed wasm/sha3_wasm_bg.7b9ca65ddd.wasm
 var embeddedWASM []byte
 
+// EmbeddedWASMSHA256 嵌入的 WASM 文件的 SHA-256，可以配合 WithExpectedWASMHash 校验自定义 WASM 文件
+const EmbeddedWASMSHA256 = "b3fca8cc072c1defbd60c02266a8e48bd307a1804aaff4314900aea720e72f7d"
+
 // DeepSeekHash 处理哈希计算
 type DeepSeekHash struct {
 	ctx      context.Context
@@ -27,27 +38,109 @@ type DeepSeekHash struct {
 	module   wazero.CompiledModule
 	instance api.Module
 	memory   api.Memory
+
+	// 导出函数在实例化时查找一次，避免每次求解都重新创建调用引擎
+	alloc        api.Function // __wbindgen_export_0
+	free         api.Function // __wbindgen_export_2
+	stackPointer api.Function // __wbindgen_add_to_stack_pointer
+	solve        api.Function // wasm_solve
+
+	stack  []uint64 // CallWithStack 复用的参数栈
+	prefix []byte   // 拼接 prefix 复用的缓冲区
+}
+
+// PoWSolver PoW 挑战求解器，返回 x-ds-pow-response 请求头的值
+// 默认使用基于嵌入 WASM 的 *DeepSeekPOW，测试时可以通过 WithPoWSolver 替换为不依赖 wazero 的实现
+// 客户端会在多个 goroutine 中同时调用 SolveChallenge，实现必须是并发安全的
+type PoWSolver interface {
+	SolveChallenge(ctx context.Context, config ChallengeConfig) (string, error)
 }
 
 // DeepSeekPOW 处理 DeepSeek 的 Proof of Work 挑战
+// 可以被多个 goroutine 同时调用，求解在内部串行执行；需要并行求解时使用 PoWPool
 type DeepSeekPOW struct {
+	mu     sync.Mutex // WASM 实例不支持并发调用，求解需要串行执行
 	hasher *DeepSeekHash
+	sha256 string // 加载的 WASM 文件的 SHA-256，十六进制小写
+
+	// difficultyOverride 大于 0 时代替挑战中的难度（答案的搜索上限），只供包内测试设置：
+	// 小于答案的值让求解快速失败，配合无解的挑战使用很大的值让求解持续很久
+	difficultyOverride float64
 }
 
 // ChallengeConfig 表示 PoW 挑战的配置
 type ChallengeConfig struct {
-	Algorithm  string `json:"algorithm"`
-	Challenge  string `json:"challenge"`
-	Salt       string `json:"salt"`
-	Difficulty int    `json:"difficulty"`
-	ExpireAt   int    `json:"expire_at"`
-	Signature  string `json:"signature"`
-	TargetPath string `json:"target_path"`
+	Algorithm  string  `json:"algorithm"`
+	Challenge  string  `json:"challenge"`
+	Salt       string  `json:"salt"`
+	Difficulty float64 `json:"difficulty"`
+	ExpireAt   int64   `json:"expire_at"`
+	Signature  string  `json:"signature"`
+	TargetPath string  `json:"target_path"`
+}
+
+// validateChallenge 在求解前检查挑战的必需字段和有效期，避免浪费一次求解后才被服务端拒绝
+// expire_at 为毫秒时间戳，为 0 时表示服务端没有下发有效期，不做检查
+func validateChallenge(config ChallengeConfig, now time.Time) error {
+	var missing []string
+	if config.Algorithm == "" {
+		missing = append(missing, "algorithm")
+	}
+	if config.Challenge == "" {
+		missing = append(missing, "challenge")
+	}
+	if config.Salt == "" {
+		missing = append(missing, "salt")
+	}
+	if config.Signature == "" {
+		missing = append(missing, "signature")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid challenge: missing %s", strings.Join(missing, ", "))
+	}
+
+	if config.Difficulty <= 0 {
+		return fmt.Errorf("invalid challenge: difficulty must be positive, got %v", config.Difficulty)
+	}
+	if challengeExpired(config, now) {
+		return fmt.Errorf("invalid challenge: expired at %s", time.UnixMilli(config.ExpireAt).Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// powExpiryMargin 挑战剩余有效期少于该值时视为即将过期，求解前重新获取
+const powExpiryMargin = 5 * time.Second
+
+// challengeExpired 判断挑战在 t 时是否已经过期，expire_at 为 0 时视为不会过期
+func challengeExpired(config ChallengeConfig, t time.Time) bool {
+	return config.ExpireAt > 0 && !t.Before(time.UnixMilli(config.ExpireAt))
+}
+
+// powConfig 创建 PoW 求解器时的可选配置
+type powConfig struct {
+	runtimeConfig wazero.RuntimeConfig // 为 nil 时使用 wazero.NewRuntimeConfig()
+	logger        Logger
+	expectedHash  string // 不为空时校验 WASM 文件的 SHA-256
 }
 
 // NewDeepSeekPOW 创建一个新的 PoW 求解器
 // 如果 wasmPath 为空，使用嵌入的 WASM 文件；否则从文件系统读取
 func NewDeepSeekPOW(wasmPath string) (*DeepSeekPOW, error) {
+	return newDeepSeekPOW(wasmPath, powConfig{logger: defaultLogger{}})
+}
+
+// newDeepSeekPOW 按配置创建 PoW 求解器
+func newDeepSeekPOW(wasmPath string, cfg powConfig) (*DeepSeekPOW, error) {
+	wasmBytes, err := loadWASM(wasmPath, cfg.logger)
+	if err != nil {
+		return nil, err
+	}
+	return newDeepSeekPOWFromBytes(wasmBytes, cfg)
+}
+
+// loadWASM 读取 WASM 文件，wasmPath 为空时返回嵌入的 WASM 文件
+func loadWASM(wasmPath string, logger Logger) ([]byte, error) {
 	var wasmBytes []byte
 	var err error
 
@@ -57,35 +150,89 @@ func NewDeepSeekPOW(wasmPath string) (*DeepSeekPOW, error) {
 		if len(wasmBytes) == 0 {
 			return nil, fmt.Errorf("embedded WASM file is empty")
 		}
-		debugPrint("Using embedded WASM file (size: %d bytes)", len(wasmBytes))
+		logger.Debugf("Using embedded WASM file (size: %d bytes)", len(wasmBytes))
 	} else {
 		// 从文件系统读取
 		wasmBytes, err = os.ReadFile(wasmPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read WASM file: %w", err)
 		}
-		debugPrint("Using WASM file from disk: %s (size: %d bytes)", wasmPath, len(wasmBytes))
+		logger.Debugf("Using WASM file from disk: %s (size: %d bytes)", wasmPath, len(wasmBytes))
+	}
+
+	return wasmBytes, nil
+}
+
+// NewDeepSeekPOWFromReader 从 r 读取 WASM 文件并创建 PoW 求解器，例如从对象存储下载的新版本
+// 返回前会完成模块的编译和实例化，WASM 无效时返回错误
+func NewDeepSeekPOWFromReader(r io.Reader) (*DeepSeekPOW, error) {
+	wasmBytes, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WASM: %w", err)
+	}
+	return newDeepSeekPOWFromBytes(wasmBytes, powConfig{logger: defaultLogger{}})
+}
+
+// NewDeepSeekPOWFromFS 从 fsys 中的 name 文件创建 PoW 求解器，例如调用方自己的 embed.FS
+// 返回前会完成模块的编译和实例化，WASM 无效时返回错误
+func NewDeepSeekPOWFromFS(fsys fs.FS, name string) (*DeepSeekPOW, error) {
+	wasmBytes, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WASM file: %w", err)
+	}
+	return newDeepSeekPOWFromBytes(wasmBytes, powConfig{logger: defaultLogger{}})
+}
+
+// newDeepSeekPOWFromBytes 从 WASM 字节数据创建 PoW 求解器
+func newDeepSeekPOWFromBytes(wasmBytes []byte, cfg powConfig) (*DeepSeekPOW, error) {
+	if len(wasmBytes) == 0 {
+		return nil, fmt.Errorf("WASM module is empty")
 	}
 
-	hasher, err := newDeepSeekHashFromBytes(wasmBytes)
+	sum := sha256.Sum256(wasmBytes)
+	hash := hex.EncodeToString(sum[:])
+	if cfg.expectedHash != "" && !strings.EqualFold(cfg.expectedHash, hash) {
+		return nil, fmt.Errorf("WASM hash mismatch: expected %s, got %s", cfg.expectedHash, hash)
+	}
+
+	hasher, err := newDeepSeekHashFromBytes(wasmBytes, cfg.runtimeConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DeepSeekPOW{
 		hasher: hasher,
+		sha256: hash,
 	}, nil
 }
 
+// WASMSHA256 返回加载的 WASM 文件的 SHA-256（十六进制小写），可以用于确认运行的是预期的文件
+func (p *DeepSeekPOW) WASMSHA256() string {
+	return p.sha256
+}
+
 // newDeepSeekHashFromBytes 从字节数据创建哈希计算器
-func newDeepSeekHashFromBytes(wasmBytes []byte) (*DeepSeekHash, error) {
+// runtimeConfig 为 nil 时使用 wazero.NewRuntimeConfig()；无论传入什么配置都会开启 WithCloseOnContextDone，
+// 否则 wasm_solve 在 ctx 取消后仍会一直运行到求解结束
+func newDeepSeekHashFromBytes(wasmBytes []byte, runtimeConfig wazero.RuntimeConfig) (*DeepSeekHash, error) {
 	ctx := context.Background()
 	hash := &DeepSeekHash{
 		ctx: ctx,
 	}
 
-	// 创建运行时
-	hash.runtime = wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig())
+	if runtimeConfig == nil {
+		runtimeConfig = wazero.NewRuntimeConfig()
+	}
+	runtimeConfig = runtimeConfig.WithCloseOnContextDone(true)
+
+	// 创建运行时，初始化失败时释放，避免无效的 WASM 泄漏运行时
+	hash.runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+	initialized := false
+	defer func() {
+		if !initialized {
+			hash.runtime.Close(ctx)
+		}
+	}()
 
 	// 设置 WASI
 	_, err := wasi_snapshot_preview1.Instantiate(ctx, hash.runtime)
@@ -100,18 +247,46 @@ func newDeepSeekHashFromBytes(wasmBytes []byte) (*DeepSeekHash, error) {
 	}
 
 	// 实例化模块
-	hash.instance, err = hash.runtime.InstantiateModule(ctx, hash.module, wazero.NewModuleConfig())
+	if err := hash.instantiate(); err != nil {
+		return nil, err
+	}
+
+	// wasm_solve 参数最多，为 6 个
+	hash.stack = make([]uint64, 6)
+
+	initialized = true
+	return hash, nil
+}
+
+// instantiate 实例化编译好的模块并查找内存和求解需要的导出函数
+// 求解被 ctx 中断后 wazero 会关闭实例，此时调用它重新创建一个
+func (h *DeepSeekHash) instantiate() error {
+	instance, err := h.runtime.InstantiateModule(h.ctx, h.module, wazero.NewModuleConfig())
 	if err != nil {
-		return nil, fmt.Errorf("failed to instantiate WASM module: %w", err)
+		return fmt.Errorf("failed to instantiate WASM module: %w", err)
 	}
+	h.instance = instance
 
 	// 获取内存
-	hash.memory = hash.instance.ExportedMemory("memory")
-	if hash.memory == nil {
-		return nil, fmt.Errorf("memory not found in WASM module")
+	if h.memory = instance.ExportedMemory("memory"); h.memory == nil {
+		return fmt.Errorf("memory not found in WASM module")
 	}
 
-	return hash, nil
+	// 获取求解需要的导出函数
+	if h.alloc = instance.ExportedFunction("__wbindgen_export_0"); h.alloc == nil {
+		return fmt.Errorf("__wbindgen_export_0 function not found")
+	}
+	if h.free = instance.ExportedFunction("__wbindgen_export_2"); h.free == nil {
+		return fmt.Errorf("__wbindgen_export_2 function not found")
+	}
+	if h.stackPointer = instance.ExportedFunction("__wbindgen_add_to_stack_pointer"); h.stackPointer == nil {
+		return fmt.Errorf("__wbindgen_add_to_stack_pointer function not found")
+	}
+	if h.solve = instance.ExportedFunction("wasm_solve"); h.solve == nil {
+		return fmt.Errorf("wasm_solve function not found")
+	}
+
+	return nil
 }
 
 // newDeepSeekHash 从文件路径创建哈希计算器（已废弃，保留用于兼容性）
@@ -121,63 +296,105 @@ func newDeepSeekHash(wasmPath string) (*DeepSeekHash, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read WASM file: %w", err)
 	}
-	return newDeepSeekHashFromBytes(wasmBytes)
+	return newDeepSeekHashFromBytes(wasmBytes, nil)
+}
+
+// allocate 调用 __wbindgen_export_0 在 WASM 内存中分配 size 字节
+func (h *DeepSeekHash) allocate(size uint32) (uint32, error) {
+	stack := h.stack[:2]
+	stack[0] = uint64(size)
+	stack[1] = 1
+	if err := h.alloc.CallWithStack(h.ctx, stack); err != nil {
+		return 0, fmt.Errorf("failed to allocate memory: %w", err)
+	}
+	return uint32(stack[0]), nil
+}
+
+// release 调用 __wbindgen_export_2 释放 allocate 分配的内存
+// 只用于没有交给 wasm_solve 的内存，wasm_solve 会自行释放它接管的参数
+func (h *DeepSeekHash) release(ptr, size uint32) {
+	stack := h.stack[:3]
+	stack[0] = uint64(ptr)
+	stack[1] = uint64(size)
+	stack[2] = 1
+	_ = h.free.CallWithStack(h.ctx, stack)
 }
 
 // writeToMemory 将字符串写入 WASM 内存
 func (h *DeepSeekHash) writeToMemory(text string) (uint32, uint32, error) {
-	encoded := []byte(text)
-	length := uint32(len(encoded))
-
-	// 调用 __wbindgen_export_0 分配内存
-	wbindgenExport0 := h.instance.ExportedFunction("__wbindgen_export_0")
-	if wbindgenExport0 == nil {
-		return 0, 0, fmt.Errorf("__wbindgen_export_0 function not found")
-	}
+	length := uint32(len(text))
 
-	result, err := wbindgenExport0.Call(h.ctx, uint64(length), 1)
+	ptr, err := h.allocate(length)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to allocate memory: %w", err)
+		return 0, 0, err
 	}
 
-	ptr := uint32(result[0])
-
-	// 写入内存
-	if !h.memory.Write(ptr, encoded) {
+	// WriteString 直接拷贝字符串，不需要先转换为 []byte
+	if !h.memory.WriteString(ptr, text) {
+		h.release(ptr, length)
 		return 0, 0, fmt.Errorf("failed to write to memory")
 	}
 
 	return ptr, length, nil
 }
 
-// calculateHash 计算哈希值
-func (h *DeepSeekHash) calculateHash(algorithm, challenge, salt string, difficulty int, expireAt int) (int, error) {
-	prefix := fmt.Sprintf("%s_%d_", salt, expireAt)
+// writeBytesToMemory 将字节数据写入 WASM 内存
+func (h *DeepSeekHash) writeBytesToMemory(data []byte) (uint32, uint32, error) {
+	length := uint32(len(data))
+
+	ptr, err := h.allocate(length)
+	if err != nil {
+		return 0, 0, err
+	}
 
-	// 获取栈指针函数
-	stackPointer := h.instance.ExportedFunction("__wbindgen_add_to_stack_pointer")
-	if stackPointer == nil {
-		return 0, fmt.Errorf("__wbindgen_add_to_stack_pointer function not found")
+	if !h.memory.Write(ptr, data) {
+		h.release(ptr, length)
+		return 0, 0, fmt.Errorf("failed to write to memory")
 	}
 
+	return ptr, length, nil
+}
+
+// calculateHash 计算哈希值，ctx 用于耗时的 wasm_solve 调用
+// ctx 取消或超时会中断 wasm_solve 并返回满足 errors.Is(err, ctx.Err()) 的错误，被关闭的实例会重新创建
+// 答案使用 int64，32 位平台上 int 放不下较大的答案；difficulty 与 WASM 的参数类型一致，按原值传入
+func (h *DeepSeekHash) calculateHash(ctx context.Context, algorithm, challenge, salt string, difficulty float64, expireAt int64) (int64, error) {
+	// prefix 格式为 "{salt}_{expireAt}_"，复用缓冲区拼接以减少分配
+	h.prefix = append(h.prefix[:0], salt...)
+	h.prefix = append(h.prefix, '_')
+	h.prefix = strconv.AppendInt(h.prefix, expireAt, 10)
+	h.prefix = append(h.prefix, '_')
+
 	// 分配返回值的空间（-16 字节）
 	// 这个函数返回新的栈指针值，也就是 retptr
 	// 注意：-16 需要转换为 uint64，使用补码表示
 	// -16 的 64 位补码 = 0xFFFFFFFFFFFFFFF0
-	result, err := stackPointer.Call(h.ctx, uint64(0xFFFFFFFFFFFFFFF0))
-	if err != nil {
+	stack := h.stack[:1]
+	stack[0] = uint64(0xFFFFFFFFFFFFFFF0)
+	if err := h.stackPointer.CallWithStack(h.ctx, stack); err != nil {
 		return 0, fmt.Errorf("failed to adjust stack pointer: %w", err)
 	}
 
 	// 获取返回指针（retptr）- 这是新的栈指针值
-	// result[0] 是 uint64，需要转换为 uint32（WASM 内存地址是 32 位）
-	retptr := uint32(result[0])
+	// stack[0] 是 uint64，需要转换为 uint32（WASM 内存地址是 32 位）
+	retptr := uint32(stack[0])
 
-	// 确保在函数结束时恢复栈指针
+	// 确保在函数结束时恢复栈指针；求解被中断时实例已经重新创建，栈指针是新的，不需要恢复
+	interrupted := false
 	defer func() {
-		_, _ = stackPointer.Call(h.ctx, uint64(16))
+		if interrupted {
+			return
+		}
+		stack := h.stack[:1]
+		stack[0] = 16
+		_ = h.stackPointer.CallWithStack(h.ctx, stack)
 	}()
 
+	// challenge 和 prefix 必须分别分配：wasm_solve 按 wasm-bindgen 的约定
+	// 接管这两块内存并在返回前各自释放，合并成一次分配会导致释放错误的指针。
+	// 因此求解成功后不能再次释放它们，只有在调用 wasm_solve 之前失败时
+	// 才需要手动释放已经分配的内存，否则长期运行的客户端会泄漏 WASM 内存
+
 	// 写入 challenge 到内存
 	challengePtr, challengeLen, err := h.writeToMemory(challenge)
 	if err != nil {
@@ -185,28 +402,30 @@ func (h *DeepSeekHash) calculateHash(algorithm, challenge, salt string, difficul
 	}
 
 	// 写入 prefix 到内存
-	prefixPtr, prefixLen, err := h.writeToMemory(prefix)
+	prefixPtr, prefixLen, err := h.writeBytesToMemory(h.prefix)
 	if err != nil {
+		h.release(challengePtr, challengeLen)
 		return 0, fmt.Errorf("failed to write prefix: %w", err)
 	}
 
-	// 获取 wasm_solve 函数
-	wasmSolve := h.instance.ExportedFunction("wasm_solve")
-	if wasmSolve == nil {
-		return 0, fmt.Errorf("wasm_solve function not found")
-	}
-
 	// 调用 wasm_solve
 	// 函数签名：wasm_solve(retptr, challenge_ptr, challenge_len, prefix_ptr, prefix_len, difficulty)
-	_, err = wasmSolve.Call(h.ctx,
-		uint64(retptr),
-		uint64(challengePtr),
-		uint64(challengeLen),
-		uint64(prefixPtr),
-		uint64(prefixLen),
-		api.EncodeF64(float64(difficulty)),
-	)
-	if err != nil {
+	stack = h.stack[:6]
+	stack[0] = uint64(retptr)
+	stack[1] = uint64(challengePtr)
+	stack[2] = uint64(challengeLen)
+	stack[3] = uint64(prefixPtr)
+	stack[4] = uint64(prefixLen)
+	stack[5] = api.EncodeF64(difficulty)
+	if err := h.solve.CallWithStack(ctx, stack); err != nil {
+		// ctx 结束时 wazero 中断求解并关闭实例，需要重新实例化后才能继续使用
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			interrupted = true
+			if err := h.instantiate(); err != nil {
+				return 0, fmt.Errorf("wasm_solve interrupted: %w; failed to recreate instance: %v", ctxErr, err)
+			}
+			return 0, fmt.Errorf("wasm_solve interrupted: %w", ctxErr)
+		}
 		return 0, fmt.Errorf("failed to call wasm_solve: %w", err)
 	}
 
@@ -234,23 +453,41 @@ func (h *DeepSeekHash) calculateHash(algorithm, challenge, salt string, difficul
 	value := binary.LittleEndian.Uint64(valueBytes)
 	floatValue := math.Float64frombits(value)
 
-	return int(floatValue), nil
+	return int64(floatValue), nil
 }
 
 // SolveChallenge 解决 PoW 挑战并返回编码后的响应
-func (p *DeepSeekPOW) SolveChallenge(config ChallengeConfig) (string, error) {
+// 只支持 AlgorithmDeepSeekHashV1，其他算法返回满足 errors.Is(err, ErrUnknownAlgorithm) 的错误
+func (p *DeepSeekPOW) SolveChallenge(ctx context.Context, config ChallengeConfig) (string, error) {
+	if config.Algorithm != AlgorithmDeepSeekHashV1 {
+		return "", &PoWError{Stage: PoWStageSolve, Err: unknownAlgorithm(config.Algorithm, []string{AlgorithmDeepSeekHashV1})}
+	}
+
+	difficulty := config.Difficulty
+	if p.difficultyOverride > 0 {
+		difficulty = p.difficultyOverride
+	}
+
+	p.mu.Lock()
 	answer, err := p.hasher.calculateHash(
+		ctx,
 		config.Algorithm,
 		config.Challenge,
 		config.Salt,
-		config.Difficulty,
+		difficulty,
 		config.ExpireAt,
 	)
+	p.mu.Unlock()
 	if err != nil {
-		return "", fmt.Errorf("failed to calculate hash: %w", err)
+		return "", &PoWError{Stage: PoWStageSolve, Err: err}
 	}
 
-	// 构建结果
+	return encodePoWResponse(config, answer)
+}
+
+// encodePoWResponse 把答案和挑战信息编码为 x-ds-pow-response 请求头的值
+// answer 为 int64，32 位平台上超过 int32 的答案也能原样序列化为 JSON 整数
+func encodePoWResponse(config ChallengeConfig, answer int64) (string, error) {
 	result := map[string]interface{}{
 		"algorithm":   config.Algorithm,
 		"challenge":   config.Challenge,
@@ -263,16 +500,18 @@ func (p *DeepSeekPOW) SolveChallenge(config ChallengeConfig) (string, error) {
 	// 编码为 JSON
 	jsonData, err := json.Marshal(result)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal result: %w", err)
+		return "", &PoWError{Stage: PoWStageEncode, Err: err}
 	}
 
 	// Base64 编码
-	encoded := base64.StdEncoding.EncodeToString(jsonData)
-	return encoded, nil
+	return base64.StdEncoding.EncodeToString(jsonData), nil
 }
 
 // Close 清理资源
 func (p *DeepSeekPOW) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	if p.hasher != nil {
 		return p.hasher.Close()
 	}
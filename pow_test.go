@@ -0,0 +1,310 @@
+package dsk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// testChallenge 真实可解的挑战，答案为 testAnswer，与 dsktest.Challenge 相同
+var testChallenge = ChallengeConfig{
+	Algorithm:  AlgorithmDeepSeekHashV1,
+	Challenge:  "3dbacb26803b2a65dffcfdf9b3d1dd896dae992a7397f4ab478c2a554b55d4d5",
+	Salt:       "dsktest",
+	Difficulty: 144000,
+	ExpireAt:   4102444800000,
+	Signature:  "dsktest-signature",
+	TargetPath: "/api/v0/chat/completion",
+}
+
+const testAnswer = 4242
+
+// quickChallenge 答案只有 quickAnswer 的挑战，求解很快，用于需要大量求解的测试
+var quickChallenge = func() ChallengeConfig {
+	c := testChallenge
+	c.Challenge = "6c01834e1d3ddb8bc6da166874d91b3b506526f0add3cbc901f3097088198892"
+	return c
+}()
+
+const quickAnswer = 42
+
+// newTestPOW 使用嵌入的 WASM 创建求解器，测试结束时释放
+func newTestPOW(tb testing.TB) *DeepSeekPOW {
+	tb.Helper()
+	pow, err := NewDeepSeekPOW("")
+	if err != nil {
+		tb.Fatalf("NewDeepSeekPOW: %v", err)
+	}
+	tb.Cleanup(func() { pow.Close() })
+	return pow
+}
+
+// referenceCalculateHash 复用缓冲区之前的求解实现：每次调用都查找导出函数、
+// 用 fmt.Sprintf 拼接 prefix 并通过 Call 分配新的参数和返回值切片，用于对比新实现的结果和分配次数
+func referenceCalculateHash(h *DeepSeekHash, challenge, salt string, difficulty float64, expireAt int64) (int64, error) {
+	ctx := context.Background()
+	prefix := fmt.Sprintf("%s_%d_", salt, expireAt)
+
+	stackPointer := h.instance.ExportedFunction("__wbindgen_add_to_stack_pointer")
+	result, err := stackPointer.Call(ctx, uint64(0xFFFFFFFFFFFFFFF0))
+	if err != nil {
+		return 0, err
+	}
+	retptr := uint32(result[0])
+	defer stackPointer.Call(ctx, 16)
+
+	write := func(text string) (uint32, uint32, error) {
+		encoded := []byte(text)
+		result, err := h.instance.ExportedFunction("__wbindgen_export_0").Call(ctx, uint64(len(encoded)), 1)
+		if err != nil {
+			return 0, 0, err
+		}
+		ptr := uint32(result[0])
+		if !h.memory.Write(ptr, encoded) {
+			return 0, 0, fmt.Errorf("failed to write to memory")
+		}
+		return ptr, uint32(len(encoded)), nil
+	}
+
+	challengePtr, challengeLen, err := write(challenge)
+	if err != nil {
+		return 0, err
+	}
+	prefixPtr, prefixLen, err := write(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = h.instance.ExportedFunction("wasm_solve").Call(ctx,
+		uint64(retptr),
+		uint64(challengePtr),
+		uint64(challengeLen),
+		uint64(prefixPtr),
+		uint64(prefixLen),
+		api.EncodeF64(difficulty),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	statusBytes, _ := h.memory.Read(retptr, 4)
+	if int32(binary.LittleEndian.Uint32(statusBytes)) == 0 {
+		return 0, fmt.Errorf("WASM solve returned status 0 (no solution)")
+	}
+	valueBytes, _ := h.memory.Read(retptr+8, 8)
+	return int64(math.Float64frombits(binary.LittleEndian.Uint64(valueBytes))), nil
+}
+
+func TestCalculateHashMatchesReference(t *testing.T) {
+	pow := newTestPOW(t)
+	ctx := context.Background()
+
+	// wantAnswer 为 0 表示没有解
+	tests := []struct {
+		name       string
+		config     ChallengeConfig
+		wantAnswer int64
+	}{
+		{"solvable", testChallenge, testAnswer},
+		{"quick", quickChallenge, quickAnswer},
+		{"wrong salt", func() ChallengeConfig { c := testChallenge; c.Salt = "other"; c.Difficulty = 10000; return c }(), 0},
+		{"difficulty below answer", func() ChallengeConfig { c := testChallenge; c.Difficulty = 1000; return c }(), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			got, gotErr := pow.hasher.calculateHash(ctx, c.Algorithm, c.Challenge, c.Salt, c.Difficulty, c.ExpireAt)
+			want, wantErr := referenceCalculateHash(pow.hasher, c.Challenge, c.Salt, c.Difficulty, c.ExpireAt)
+
+			wantFound := tt.wantAnswer != 0
+			if (gotErr == nil) != wantFound || (wantErr == nil) != wantFound {
+				t.Fatalf("errors = %v, %v; want found = %v", gotErr, wantErr, wantFound)
+			}
+			if got != want {
+				t.Fatalf("answer = %d, reference = %d", got, want)
+			}
+			if got != tt.wantAnswer {
+				t.Fatalf("answer = %d, want %d", got, tt.wantAnswer)
+			}
+		})
+	}
+}
+
+func BenchmarkSolveChallenge(b *testing.B) {
+	pow := newTestPOW(b)
+	ctx := context.Background()
+	c := testChallenge
+
+	b.Run("current", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := pow.hasher.calculateHash(ctx, c.Algorithm, c.Challenge, c.Salt, c.Difficulty, c.ExpireAt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("reference", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := referenceCalculateHash(pow.hasher, c.Challenge, c.Salt, c.Difficulty, c.ExpireAt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCalculateHashMemoryBounded(t *testing.T) {
+	pow := newTestPOW(t)
+	ctx := context.Background()
+	c := quickChallenge
+
+	solve := func() {
+		t.Helper()
+		answer, err := pow.hasher.calculateHash(ctx, c.Algorithm, c.Challenge, c.Salt, c.Difficulty, c.ExpireAt)
+		if err != nil || answer != quickAnswer {
+			t.Fatalf("calculateHash = %d, %v", answer, err)
+		}
+	}
+
+	// 先求解几次，让分配器完成初始的内存增长
+	for i := 0; i < 10; i++ {
+		solve()
+	}
+	before := pow.hasher.memory.Size()
+
+	n := 2000
+	if testing.Short() {
+		n = 200
+	}
+	for i := 0; i < n; i++ {
+		solve()
+	}
+
+	if after := pow.hasher.memory.Size(); after != before {
+		t.Fatalf("WASM memory grew from %d to %d bytes after %d solves", before, after, n)
+	}
+}
+
+func TestSolveChallengeHonorsContext(t *testing.T) {
+	pow := newTestPOW(t)
+
+	// 没有解的挑战配合很大的难度，不中断时需要运行很长时间
+	slow := testChallenge
+	slow.Salt = "no-solution"
+	slow.Difficulty = 1e9
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := pow.SolveChallenge(ctx, slow)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SolveChallenge error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("SolveChallenge returned after %s, want it interrupted shortly after the 200ms deadline", elapsed)
+	}
+
+	// 被中断的实例已经重新创建，可以继续求解
+	for i := 0; i < 3; i++ {
+		if _, err := pow.SolveChallenge(context.Background(), testChallenge); err != nil {
+			t.Fatalf("SolveChallenge after interrupt: %v", err)
+		}
+	}
+	answer, err := pow.hasher.calculateHash(context.Background(), testChallenge.Algorithm, testChallenge.Challenge, testChallenge.Salt, testChallenge.Difficulty, testChallenge.ExpireAt)
+	if err != nil || answer != testAnswer {
+		t.Fatalf("calculateHash after interrupt = %d, %v; want %d", answer, err, testAnswer)
+	}
+}
+
+func TestSolveChallengeDifficultyOverride(t *testing.T) {
+	pow := newTestPOW(t)
+	ctx := context.Background()
+
+	// 搜索上限小于答案时求解快速失败
+	pow.difficultyOverride = testAnswer - 1
+	if _, err := pow.SolveChallenge(ctx, testChallenge); err == nil {
+		t.Fatal("SolveChallenge succeeded with a difficulty below the answer")
+	}
+
+	pow.difficultyOverride = testAnswer + 1
+	if _, err := pow.SolveChallenge(ctx, testChallenge); err != nil {
+		t.Fatalf("SolveChallenge with a difficulty above the answer: %v", err)
+	}
+
+	// 为 0 时使用挑战中的难度
+	pow.difficultyOverride = 0
+	low := testChallenge
+	low.Difficulty = testAnswer - 1
+	if _, err := pow.SolveChallenge(ctx, low); err == nil {
+		t.Fatal("SolveChallenge ignored the challenge difficulty")
+	}
+}
+
+func TestPoWLargeValuesRoundTrip(t *testing.T) {
+	pow := newTestPOW(t)
+
+	// 32 位平台上 int 只有 32 位，超过 math.MaxInt32 的值必须按 int64 原样解码和编码
+	tests := []struct {
+		name     string
+		expireAt int64
+		answer   int64
+	}{
+		{"small", 1700000000000, testAnswer},
+		{"answer above int32", testChallenge.ExpireAt, math.MaxInt32 + 1},
+		{"answer above uint32", testChallenge.ExpireAt, math.MaxUint32 + 1},
+		// WASM 返回 f64，答案最大为 2^53
+		{"largest exact float64 answer", math.MaxInt64, 1 << 53},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := fmt.Sprintf(`{"algorithm":%q,"challenge":%q,"salt":"dsktest","difficulty":144000,"expire_at":%d,"signature":"sig","target_path":"/api/v0/chat/completion"}`,
+				AlgorithmDeepSeekHashV1, testChallenge.Challenge, tt.expireAt)
+			var config ChallengeConfig
+			if err := json.Unmarshal([]byte(raw), &config); err != nil {
+				t.Fatalf("decode challenge: %v", err)
+			}
+			if config.ExpireAt != tt.expireAt {
+				t.Fatalf("ExpireAt = %d, want %d", config.ExpireAt, tt.expireAt)
+			}
+
+			// 求解时 expire_at 按十进制整数拼接到 prefix；难度很小，没有解也会很快返回
+			pow.hasher.calculateHash(context.Background(), config.Algorithm, config.Challenge, config.Salt, 1, config.ExpireAt)
+			if want := fmt.Sprintf("dsktest_%d_", tt.expireAt); string(pow.hasher.prefix) != want {
+				t.Fatalf("prefix = %q, want %q", pow.hasher.prefix, want)
+			}
+
+			encoded, err := encodePoWResponse(config, tt.answer)
+			if err != nil {
+				t.Fatalf("encodePoWResponse: %v", err)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				t.Fatalf("decode base64: %v", err)
+			}
+			if want := fmt.Sprintf(`"answer":%d`, tt.answer); !strings.Contains(string(decoded), want) {
+				t.Fatalf("response %s does not contain %s", decoded, want)
+			}
+			var resp struct {
+				Answer int64 `json:"answer"`
+			}
+			if err := json.Unmarshal(decoded, &resp); err != nil || resp.Answer != tt.answer {
+				t.Fatalf("decoded answer = %d, %v; want %d", resp.Answer, err, tt.answer)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package dsk
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// defaultPoWCacheSize PoW 结果缓存的默认容量
+const defaultPoWCacheSize = 64
+
+// powCacheKey 标识一个 PoW 挑战
+type powCacheKey struct {
+	algorithm string
+	challenge string
+	salt      string
+	expireAt  int64
+}
+
+// powCacheEntry 缓存的求解结果
+type powCacheEntry struct {
+	key      powCacheKey
+	response string
+}
+
+// powCache 按挑战缓存 PoW 求解结果的 LRU 缓存，结果在挑战过期后失效
+// 重试同一个请求（例如网络错误后）拿到相同挑战时可以跳过求解
+type powCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // 最近使用的在前
+	entries map[powCacheKey]*list.Element
+}
+
+// newPoWCache 创建容量为 size 的缓存
+func newPoWCache(size int) *powCache {
+	return &powCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[powCacheKey]*list.Element),
+	}
+}
+
+// keyOf 返回挑战的缓存键
+func (c *powCache) keyOf(config ChallengeConfig) powCacheKey {
+	return powCacheKey{
+		algorithm: config.Algorithm,
+		challenge: config.Challenge,
+		salt:      config.Salt,
+		expireAt:  config.ExpireAt,
+	}
+}
+
+// get 返回挑战的缓存结果，已过期的结果会被删除
+func (c *powCache) get(config ChallengeConfig, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[c.keyOf(config)]
+	if !ok {
+		return "", false
+	}
+	if challengeExpired(config, now) {
+		c.order.Remove(elem)
+		delete(c.entries, c.keyOf(config))
+		return "", false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*powCacheEntry).response, true
+}
+
+// put 缓存挑战的求解结果，超过容量时淘汰最久未使用的结果
+func (c *powCache) put(config ChallengeConfig, response string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.keyOf(config)
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*powCacheEntry).response = response
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&powCacheEntry{key: key, response: response})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*powCacheEntry).key)
+	}
+}
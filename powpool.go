@@ -0,0 +1,113 @@
+package dsk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PoWPool 由多个 WASM 实例组成的 PoW 求解器，可以同时求解多个挑战
+// 单个 DeepSeekPOW 的 WASM 实例不支持并发调用，BatchComplete 等并发场景下求解会排队，
+// 使用 PoWPool 后最多有 n 个挑战同时求解；每个实例占用独立的 WASM 内存
+type PoWPool struct {
+	idle chan *DeepSeekPOW
+	all  []*DeepSeekPOW
+}
+
+// NewPoWPool 使用嵌入的 WASM 文件创建包含 n 个实例的求解器池，n 小于 1 时按 1 处理
+func NewPoWPool(n int) (*PoWPool, error) {
+	return newPoWPool(n, "", powConfig{logger: defaultLogger{}})
+}
+
+// newPoWPool 按配置创建求解器池，WASM 文件只读取一次
+func newPoWPool(n int, wasmPath string, cfg powConfig) (*PoWPool, error) {
+	if n < 1 {
+		n = 1
+	}
+
+	wasmBytes, err := loadWASM(wasmPath, cfg.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &PoWPool{idle: make(chan *DeepSeekPOW, n)}
+	for i := 0; i < n; i++ {
+		pow, err := newDeepSeekPOWFromBytes(wasmBytes, cfg)
+		if err != nil {
+			pool.Close()
+			return nil, err
+		}
+		pool.all = append(pool.all, pow)
+		pool.idle <- pow
+	}
+
+	return pool, nil
+}
+
+// SolveChallenge 取一个空闲实例求解挑战，所有实例都在使用时等待或在 ctx 取消时返回
+func (p *PoWPool) SolveChallenge(ctx context.Context, config ChallengeConfig) (string, error) {
+	var pow *DeepSeekPOW
+	select {
+	case pow = <-p.idle:
+	case <-ctx.Done():
+		return "", &PoWError{Stage: PoWStageSolve, Err: ctx.Err()}
+	}
+	defer func() { p.idle <- pow }()
+
+	return pow.SolveChallenge(ctx, config)
+}
+
+// SolveMany 并发求解多个挑战，返回的结果与 configs 顺序一致
+// 并发数不超过池中的实例数；任意一个挑战失败时返回第一个错误，其余结果仍会填充
+func (p *PoWPool) SolveMany(ctx context.Context, configs []ChallengeConfig) ([]string, error) {
+	return solveMany(ctx, p, configs)
+}
+
+// solveMany 用 solver 并发求解多个挑战，实际的并发数由 solver 决定（例如 PoWPool 的实例数）
+// 失败的挑战对应的结果为空字符串，返回第一个错误
+func solveMany(ctx context.Context, solver PoWSolver, configs []ChallengeConfig) ([]string, error) {
+	results := make([]string, len(configs))
+	errs := make([]error, len(configs))
+
+	var wg sync.WaitGroup
+	for i := range configs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = solver.SolveChallenge(ctx, configs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return results, fmt.Errorf("challenge %d: %w", i, err)
+		}
+	}
+	return results, nil
+}
+
+// Size 返回池中的实例数
+func (p *PoWPool) Size() int {
+	return len(p.all)
+}
+
+// WASMSHA256 返回加载的 WASM 文件的 SHA-256（所有实例使用同一个文件）
+func (p *PoWPool) WASMSHA256() string {
+	if len(p.all) == 0 {
+		return ""
+	}
+	return p.all[0].WASMSHA256()
+}
+
+// Close 释放所有实例，正在求解的实例会等待求解结束后再释放
+func (p *PoWPool) Close() error {
+	var errs []error
+	for _, pow := range p.all {
+		if err := pow.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package dsk
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPoWPoolSolveMany(t *testing.T) {
+	pool, err := NewPoWPool(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	bad := quickChallenge
+	bad.Algorithm = "unknown"
+	results, err := pool.SolveMany(context.Background(), []ChallengeConfig{quickChallenge, bad, quickChallenge})
+	if err == nil {
+		t.Fatal("SolveMany succeeded with an unknown algorithm")
+	}
+	if results[0] == "" || results[1] != "" || results[2] == "" {
+		t.Fatalf("results = %q, want the failed challenge empty and the others filled", results)
+	}
+}
+
+// BenchmarkPoWPoolSolveMany 对比逐个求解和用 4 个实例并发求解 8 个挑战的吞吐
+func BenchmarkPoWPoolSolveMany(b *testing.B) {
+	pool, err := NewPoWPool(4)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	configs := make([]ChallengeConfig, 8)
+	for i := range configs {
+		configs[i] = testChallenge
+	}
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, config := range configs {
+				if _, err := pool.SolveChallenge(ctx, config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("SolveMany", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := pool.SolveMany(ctx, configs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// AlgorithmDeepSeekHashV1 嵌入的 WASM 求解器支持的 PoW 算法
+const AlgorithmDeepSeekHashV1 = "DeepSeekHashV1"
+
+// PoWRegistry 按 ChallengeConfig.Algorithm 选择求解器的 PoWSolver
+// DeepSeek 更换算法时可以注册新的求解器，而不会把挑战交给不支持它的求解器
+type PoWRegistry struct {
+	mu      sync.RWMutex
+	solvers map[string]PoWSolver
+}
+
+// NewPoWRegistry 创建空的求解器注册表
+func NewPoWRegistry() *PoWRegistry {
+	return &PoWRegistry{solvers: make(map[string]PoWSolver)}
+}
+
+// Register 为 algorithm 注册求解器，已注册的同名算法会被替换
+func (r *PoWRegistry) Register(algorithm string, solver PoWSolver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.solvers[algorithm] = solver
+}
+
+// Algorithms 返回已注册的算法名，按字母顺序排列
+func (r *PoWRegistry) Algorithms() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.solvers))
+	for name := range r.solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SolveChallenge 使用 config.Algorithm 对应的求解器求解
+// 没有对应求解器时返回的错误满足 errors.Is(err, ErrUnknownAlgorithm)
+func (r *PoWRegistry) SolveChallenge(ctx context.Context, config ChallengeConfig) (string, error) {
+	r.mu.RLock()
+	solver, ok := r.solvers[config.Algorithm]
+	r.mu.RUnlock()
+
+	if !ok {
+		return "", &PoWError{Stage: PoWStageSolve, Err: unknownAlgorithm(config.Algorithm, r.Algorithms())}
+	}
+	return solver.SolveChallenge(ctx, config)
+}
+
+// unknownAlgorithm 构造不支持的算法的错误
+func unknownAlgorithm(algorithm string, supported []string) error {
+	return fmt.Errorf("%w: %q (supported: %v)", ErrUnknownAlgorithm, algorithm, supported)
+}
@@ -0,0 +1,59 @@
+package dsk
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 表示对话中的一条消息
+// 作为 BuildPrompt 的输入时只使用 Role 和 Content；CompleteMessage 和 CollectMessage 返回的回答
+// 会填写其余字段，适合直接保存为对话历史
+type Message struct {
+	Role    string // RoleSystem、RoleUser 或 RoleAssistant
+	Content string
+
+	ID           string      // 消息 ID，可以作为下一轮的 parentMessageID
+	ParentID     string      // 请求时的父消息 ID，会话的第一轮为空
+	Thinking     string      // 思考过程，只有开启思考的回答才有
+	FinishReason string      // 结束原因，见 FinishReasonStop 等常量
+	CreatedAt    time.Time   // 收到第一个数据块的时间
+	Usage        *TokenUsage // token 用量，服务端没有返回时为 nil
+}
+
+// BuildPrompt 把 system/user/assistant 多轮消息合并为 DeepSeek 需要的单条 prompt
+// 只有一条 user 消息时直接返回其内容，否则每条消息加上 "System: "、"User: "、"Assistant: " 前缀并用空行分隔。
+//
+// 与使用 parent_message_id 的会话多轮对话相比：合并后的 prompt 每次都会把完整历史作为一条新消息发送，
+// 适合从无状态的聊天 SDK 迁移，但历史越长消耗的上下文越多，且模型看到的是文本形式的历史而不是真正的多轮对话；
+// 如果对话本来就在同一个 DeepSeek 会话中进行，优先只发送最新的 user 消息并传入 parentMessageID
+func BuildPrompt(messages []Message) string {
+	if len(messages) == 1 && messages[0].Role == RoleUser {
+		return messages[0].Content
+	}
+
+	var sb strings.Builder
+	for _, msg := range messages {
+		if msg.Content == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		switch msg.Role {
+		case RoleSystem:
+			sb.WriteString("System: ")
+		case RoleAssistant:
+			sb.WriteString("Assistant: ")
+		default:
+			sb.WriteString("User: ")
+		}
+		sb.WriteString(msg.Content)
+	}
+	return sb.String()
+}
@@ -0,0 +1,29 @@
+package dsk
+
+import "context"
+
+// Quick 一次性完成“创建客户端 → 创建会话 → 获取完整回答 → 关闭客户端”，适合脚本和示例代码
+// 注意每次调用都会重新编译 WASM 并创建新会话，开销较大；需要多次请求时应使用
+// NewDeepSeekAPI 创建一个客户端并复用
+// ctx 在回答过程中被取消时，返回已经收到的部分回答和 ctx.Err()
+func Quick(ctx context.Context, token, prompt string, opts ...Option) (string, error) {
+	api, err := NewDeepSeekAPI(token, opts...)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := quickComplete(ctx, api, prompt)
+	if closeErr := api.Close(ctx); err == nil {
+		err = closeErr
+	}
+	return text, err
+}
+
+// quickComplete 在新会话中发送 prompt 并返回完整回答
+func quickComplete(ctx context.Context, api *DeepSeekAPI, prompt string) (string, error) {
+	sessionID, err := api.CreateChatSessionContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	return CollectResponse(api.Complete(ctx, sessionID, prompt))
+}
@@ -0,0 +1,77 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// completionReader 把流式响应的回答内容适配为 io.ReadCloser
+type completionReader struct {
+	cancel    context.CancelFunc
+	chunkChan <-chan Chunk
+	errChan   <-chan error
+	buf       []byte
+	started   bool  // 是否已经收到过回答内容
+	err       error // 流结束后 Read 返回的错误，正常结束时为 io.EOF
+}
+
+// CompletionReader 发送消息并以 io.ReadCloser 返回回答内容（不包含思考过程），
+// 可以直接传给 io.Copy 等接收 io.Reader 的函数
+// 回答结束时 Read 返回 io.EOF，请求失败时返回对应的错误；Close 会取消尚未结束的请求
+// 开启 WithStreamReconnect 时，服务端在已经输出部分回答后重新生成会导致已经读出的内容无法撤回，此时取消请求并返回错误
+func (api *DeepSeekAPI) CompletionReader(ctx context.Context, chatSessionID, prompt string, opts ...CompletionOption) io.ReadCloser {
+	ctx, cancel := context.WithCancel(ctx)
+	chunkChan, errChan := api.Complete(ctx, chatSessionID, prompt, opts...)
+	return &completionReader{
+		cancel:    cancel,
+		chunkChan: chunkChan,
+		errChan:   errChan,
+	}
+}
+
+func (r *completionReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		chunk, ok := <-r.chunkChan
+		if !ok {
+			r.err = io.EOF
+			if err := <-r.errChan; err != nil {
+				r.err = err
+			}
+			continue
+		}
+		switch chunk.Type {
+		case "thinking":
+		case "restart":
+			if r.started {
+				r.cancel()
+				r.buf = nil
+				r.err = fmt.Errorf("answer was restarted after reconnecting, content already read is no longer valid")
+			}
+		default:
+			r.started = r.started || chunk.Content != ""
+			r.buf = append(r.buf, chunk.Content...)
+		}
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// Close 取消请求并等待流结束
+func (r *completionReader) Close() error {
+	r.cancel()
+	r.buf = nil
+	if r.err == nil {
+		for range r.chunkChan {
+		}
+		<-r.errChan
+		r.err = io.ErrClosedPipe
+	}
+	return nil
+}
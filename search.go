@@ -0,0 +1,94 @@
+package dsk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// SearchResult 联网搜索返回的一条结果
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// Search 在会话中以 query 发起一次开启联网搜索的对话，只返回搜索结果
+//
+// 网页版接口没有单独的搜索接口，搜索结果是对话响应中的一个事件，因此：
+//   - 这次请求会消耗一次对话（包括 PoW），并在会话中留下这条消息
+//   - 收到搜索结果后，模型一开始输出回答就会取消请求，会话中的回答可能不完整
+//   - 结果是尽力而为的：模型判断不需要搜索或服务端调整了事件格式时，返回空切片和 nil
+func (api *DeepSeekAPI) Search(ctx context.Context, chatSessionID, query string) ([]SearchResult, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	raw := make(chan json.RawMessage, 16)
+	chunkChan, errChan := api.Complete(ctx, chatSessionID, query, WithSearch(), WithRawEvents(raw))
+
+	var results []SearchResult
+	cancelled := false
+	for raw != nil || chunkChan != nil {
+		select {
+		case data, ok := <-raw:
+			if !ok {
+				raw = nil
+				continue
+			}
+			results = append(results, parseSearchResults(data)...)
+		case chunk, ok := <-chunkChan:
+			if !ok {
+				chunkChan = nil
+				continue
+			}
+			// 搜索在回答之前完成，开始输出回答后不再需要后面的内容
+			if chunk.Type == "text" && chunk.Content != "" && len(results) > 0 && !cancelled {
+				cancelled = true
+				cancel()
+			}
+		}
+	}
+
+	if err := <-errChan; err != nil && !(cancelled && errors.Is(err, context.Canceled)) {
+		return nil, err
+	}
+	if results == nil {
+		results = []SearchResult{}
+	}
+	return results, nil
+}
+
+// parseSearchResults 从一个 SSE 事件中提取搜索结果，支持
+// {"p":"response/search_results","v":[...]} 和 delta 中 {"type":"search_result","results":[...]} 两种格式
+func parseSearchResults(data json.RawMessage) []SearchResult {
+	var event struct {
+		P       string          `json:"p"`
+		V       json.RawMessage `json:"v"`
+		Choices []struct {
+			Delta struct {
+				Type    string          `json:"type"`
+				Results json.RawMessage `json:"results"`
+			} `json:"delta"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(data, &event); err != nil {
+		return nil
+	}
+
+	var list json.RawMessage
+	switch {
+	case strings.HasSuffix(event.P, "search_results"):
+		list = event.V
+	case len(event.Choices) > 0 && event.Choices[0].Delta.Type == "search_result":
+		list = event.Choices[0].Delta.Results
+	default:
+		return nil
+	}
+
+	var results []SearchResult
+	if err := json.Unmarshal(list, &results); err != nil {
+		return nil
+	}
+	return results
+}
@@ -0,0 +1,37 @@
+package dsk
+
+import (
+	"io"
+	"time"
+)
+
+// StreamStats 一次流式请求的统计，用于诊断服务端响应格式的变化，通过 WithStreamStats 获取
+type StreamStats struct {
+	Lines      int           // 读取的行数，包括分隔事件的空行和注释行
+	DataLines  int           // 其中 data 行的数量
+	Comments   int           // 其中以冒号开头的注释行（心跳）的数量
+	Bytes      int64         // 读取的响应体字节数
+	Chunks     int           // 发送到 chunkChan 的数据块数量
+	Reconnects int           // 连接中断后重新连接的次数，见 WithStreamReconnect
+	Duration   time.Duration // 从开始处理请求（包括 PoW）到流结束的时间
+}
+
+// WithStreamStats 在流结束后把本次请求的统计写入 stats
+// 写入发生在 chunkChan 和 errChan 关闭之前，读完两个通道后即可安全读取；请求在发送前失败时各项计数为 0
+func WithStreamStats(stats *StreamStats) CompletionOption {
+	return completionOptionFunc(func(opts *CompletionOptions) {
+		opts.StreamStats = stats
+	})
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,98 @@
+package dsk
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// idleTimeoutReader 在连续 timeout 时间没有读到数据时调用 onIdle
+// 每次读到数据都会重置计时器，onIdle 通常用于取消请求的 ctx，使阻塞中的 Read 返回
+type idleTimeoutReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// newIdleTimeoutReader 创建 idleTimeoutReader 并立即开始计时
+func newIdleTimeoutReader(r io.Reader, timeout time.Duration, onIdle func()) *idleTimeoutReader {
+	return &idleTimeoutReader{
+		r:       r,
+		timeout: timeout,
+		timer:   time.AfterFunc(timeout, onIdle),
+	}
+}
+
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// Stop 停止计时，流结束后需要调用
+func (r *idleTimeoutReader) Stop() {
+	r.timer.Stop()
+}
+
+// sseEvent 一个完整的 SSE 事件
+type sseEvent struct {
+	Name       string    // event: 字段
+	ID         string    // id: 字段，未重新设置时沿用上一个事件的值
+	Data       string    // 所有 data: 行以换行符拼接后的内容
+	ReceivedAt time.Time // 收到第一行 data: 的时间
+}
+
+// parseSSELine 把一行 SSE 拆分为字段名和值，值按规范去掉冒号后的一个空格
+// 空行和以冒号开头的注释行返回 ok 为 false
+func parseSSELine(line string) (field, value string, ok bool) {
+	if line == "" || strings.HasPrefix(line, ":") {
+		return "", "", false
+	}
+	field, value, _ = strings.Cut(line, ":")
+	return field, strings.TrimPrefix(value, " "), true
+}
+
+// peekBuffered 等待至少一个字节到达后返回已经缓冲的内容（最多 n 字节），不消耗数据
+func peekBuffered(r *bufio.Reader, n int) []byte {
+	if _, err := r.Peek(1); err != nil {
+		return nil
+	}
+	peek, _ := r.Peek(min(r.Buffered(), n))
+	return peek
+}
+
+// looksLikeSSE 根据第一行判断内容是否为 SSE 格式：空行、注释或 data/event/id/retry 字段
+func looksLikeSSE(b []byte) bool {
+	line, _, _ := bytes.Cut(b, []byte("\n"))
+	field, _, ok := parseSSELine(strings.TrimRight(string(line), "\r"))
+	if !ok {
+		return true
+	}
+	switch field {
+	case "data", "event", "id", "retry":
+		return true
+	}
+	return false
+}
+
+// readLine 读取一行（包含结尾的换行符），行的长度没有上限，除非 maxSize 大于 0
+// 超过 maxSize 时返回 ErrLineTooLong；流结束时与 bufio.Reader.ReadString 一样返回剩余内容和 io.EOF
+func readLine(r *bufio.Reader, maxSize int) (string, error) {
+	var line []byte
+	for {
+		frag, err := r.ReadSlice('\n')
+		if maxSize > 0 && len(line)+len(frag) > maxSize {
+			return "", fmt.Errorf("%w: exceeds %d bytes", ErrLineTooLong, maxSize)
+		}
+		line = append(line, frag...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return string(line), err
+	}
+}
@@ -0,0 +1,104 @@
+package dsk
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseSSELine(t *testing.T) {
+	tests := []struct {
+		line      string
+		wantField string
+		wantValue string
+		wantOK    bool
+	}{
+		{`data: {"v":"a"}`, "data", `{"v":"a"}`, true},
+		{`data:{"v":"a"}`, "data", `{"v":"a"}`, true},
+		// 只去掉一个空格，其余空格属于内容
+		{"data:  two spaces", "data", " two spaces", true},
+		{"data:", "data", "", true},
+		{"event: ready", "event", "ready", true},
+		{"id:42", "id", "42", true},
+		{": keep-alive", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		field, value, ok := parseSSELine(tt.line)
+		if field != tt.wantField || value != tt.wantValue || ok != tt.wantOK {
+			t.Errorf("parseSSELine(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.line, field, value, ok, tt.wantField, tt.wantValue, tt.wantOK)
+		}
+	}
+}
+
+func TestReadLineLongerThanBuffer(t *testing.T) {
+	// 远大于 bufio.Reader 默认的 4KB 缓冲区和 bufio.Scanner 的 64KB 上限
+	long := "data: " + strings.Repeat("x", 200<<10) + "\n"
+	r := bufio.NewReader(strings.NewReader(long + "data: next\n"))
+
+	line, err := readLine(r, 0)
+	if err != nil || line != long {
+		t.Fatalf("readLine = %d bytes, %v; want %d bytes", len(line), err, len(long))
+	}
+	line, err = readLine(r, 0)
+	if err != nil || line != "data: next\n" {
+		t.Fatalf("second readLine = %q, %v", line, err)
+	}
+	if _, err := readLine(r, 0); err != io.EOF {
+		t.Fatalf("readLine at end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadLineMaxSize(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(strings.Repeat("x", 10000) + "\n"))
+	if _, err := readLine(r, 8192); !errors.Is(err, ErrLineTooLong) {
+		t.Fatalf("readLine error = %v, want ErrLineTooLong", err)
+	}
+}
+
+// splitReader 按 sizes 循环切分每次 Read 返回的字节数，模拟服务端在一行中途刷新
+type splitReader struct {
+	data  string
+	sizes []int
+	i     int
+}
+
+func (r *splitReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, io.EOF
+	}
+	n := min(r.sizes[r.i%len(r.sizes)], len(r.data))
+	n = copy(p[:min(n, len(p))], r.data)
+	r.data = r.data[n:]
+	r.i++
+	return n, nil
+}
+
+func TestReadLineAwkwardSplits(t *testing.T) {
+	lines := []string{
+		`data: {"choices":[{"delta":{"type":"text","content":"你好，世界"}}]}` + "\n",
+		"\n",
+		`data: {"v":"split"}` + "\r\n",
+	}
+	// 最后一行没有换行符，连接在一行中途结束
+	partial := `data: {"v":"tail`
+	input := strings.Join(lines, "") + partial
+
+	for _, sizes := range [][]int{{1}, {2, 5}, {7, 1, 13}, {64}} {
+		r := bufio.NewReaderSize(&splitReader{data: input, sizes: sizes}, 16)
+		for i, want := range lines {
+			got, err := readLine(r, 0)
+			if err != nil || got != want {
+				t.Fatalf("sizes %v: line %d = %q, %v; want %q", sizes, i, got, err, want)
+			}
+		}
+		got, err := readLine(r, 0)
+		if err != io.EOF || got != partial {
+			t.Fatalf("sizes %v: trailing line = %q, %v; want %q, io.EOF", sizes, got, err, partial)
+		}
+	}
+}
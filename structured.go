@@ -0,0 +1,94 @@
+package dsk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONError 模型输出无法解析为目标类型时返回的错误
+// Text 保留去掉代码块标记后的原始输出，便于排查或重试
+type JSONError struct {
+	Text string // 尝试解析的文本
+	Err  error  // json.Unmarshal 返回的错误
+}
+
+func (e *JSONError) Error() string {
+	return fmt.Sprintf("malformed JSON output: %v", e.Err)
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// JSONOption CompleteJSON 的可选配置
+type JSONOption func(*jsonConfig)
+
+type jsonConfig struct {
+	schemaHint     string
+	completionOpts []CompletionOption
+}
+
+// WithSchemaHint 在 prompt 末尾追加期望的 JSON 结构说明，例如 JSON Schema 或示例对象
+func WithSchemaHint(schema string) JSONOption {
+	return func(c *jsonConfig) {
+		c.schemaHint = schema
+	}
+}
+
+// WithJSONCompletionOptions 把 WithThinking、WithParent 等选项传给底层的 Complete 请求
+// 不设置时使用客户端的默认值，见 WithDefaultThinking 和 WithDefaultSearch
+func WithJSONCompletionOptions(opts ...CompletionOption) JSONOption {
+	return func(c *jsonConfig) {
+		c.completionOpts = append(c.completionOpts, opts...)
+	}
+}
+
+// CompleteJSON 发送 prompt 并把完整回答解析为 T
+// 回答外层的 ``` 或 ```json 代码块标记会被去掉；解析失败时返回 *JSONError
+func CompleteJSON[T any](ctx context.Context, api APIClient, chatSessionID, prompt string, opts ...JSONOption) (T, error) {
+	var result T
+
+	var cfg jsonConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.schemaHint != "" {
+		prompt = fmt.Sprintf("%s\n\nRespond with JSON only, matching this schema:\n%s", prompt, cfg.schemaHint)
+	}
+
+	text, err := CollectResponse(api.Complete(ctx, chatSessionID, prompt, cfg.completionOpts...))
+	if err != nil {
+		return result, err
+	}
+
+	text = stripCodeFence(text)
+	if err := json.Unmarshal([]byte(text), &result); err != nil {
+		return result, &JSONError{Text: text, Err: err}
+	}
+
+	return result, nil
+}
+
+// stripCodeFence 取出文本中第一个 Markdown 代码块的内容，没有代码块时返回去掉首尾空白的文本
+func stripCodeFence(text string) string {
+	start := strings.Index(text, "```")
+	if start < 0 {
+		return strings.TrimSpace(text)
+	}
+
+	// 跳过 ``` 所在行剩余的语言标记，例如 ```json
+	body := text[start+3:]
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:]
+	} else {
+		body = ""
+	}
+
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
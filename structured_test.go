@@ -0,0 +1,73 @@
+package dsk_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+)
+
+type fruits struct {
+	Items []string `json:"items"`
+}
+
+func TestCompleteJSONForwardsCompletionOptions(t *testing.T) {
+	answer := "```json\n{\"items\":[\"apple\",\"pear\"]}\n```"
+	tests := []struct {
+		name         string
+		clientOpts   []dsk.Option
+		opts         []dsk.JSONOption
+		wantThinking bool
+		wantSearch   bool
+		wantParent   interface{}
+	}{
+		{"defaults", nil, nil, false, false, nil},
+		{"client defaults", []dsk.Option{dsk.WithDefaultThinking(true), dsk.WithDefaultSearch(true)}, nil, true, true, nil},
+		{"completion options", nil,
+			[]dsk.JSONOption{dsk.WithJSONCompletionOptions(dsk.WithThinking(), dsk.WithParent("7"))}, true, false, "7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &requestRecorder{next: dsktest.NewHandler(dsktest.MockOptions{
+				Events: []string{dsktest.TextEvent(answer), dsktest.StopEvent()},
+			})}
+			srv := httptest.NewServer(recorder)
+			defer srv.Close()
+			api := newTestClient(t, srv, tt.clientOpts...)
+
+			got, err := dsk.CompleteJSON[fruits](context.Background(), api, dsktest.SessionID, "fruits", tt.opts...)
+			if err != nil {
+				t.Fatalf("CompleteJSON: %v", err)
+			}
+			if len(got.Items) != 2 || got.Items[0] != "apple" {
+				t.Fatalf("CompleteJSON = %+v", got)
+			}
+
+			body := recorder.find("/chat/completion")[0].Body
+			if body["thinking_enabled"] != tt.wantThinking || body["search_enabled"] != tt.wantSearch {
+				t.Fatalf("thinking_enabled = %v, search_enabled = %v; want %v, %v",
+					body["thinking_enabled"], body["search_enabled"], tt.wantThinking, tt.wantSearch)
+			}
+			if body["parent_message_id"] != tt.wantParent {
+				t.Fatalf("parent_message_id = %v, want %v", body["parent_message_id"], tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestCompleteJSONMalformed(t *testing.T) {
+	srv := dsktest.NewMockServer(dsktest.MockOptions{
+		Events: []string{dsktest.TextEvent("not json"), dsktest.StopEvent()},
+	})
+	defer srv.Close()
+
+	_, err := dsk.CompleteJSON[fruits](context.Background(), newTestClient(t, srv), dsktest.SessionID, "fruits")
+	var jsonErr *dsk.JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Text != "not json" {
+		t.Fatalf("CompleteJSON error = %v, want *JSONError with the raw text", err)
+	}
+}
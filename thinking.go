@@ -0,0 +1,63 @@
+package dsk
+
+import "strings"
+
+// AggregateThinking 把流中所有思考数据块合并为一个，在思考阶段结束时（收到 answer_start 或第一个回答内容、
+// 结束信号或流结束）一次性发送，回答数据块原样转发
+// 适合把思考过程渲染为可折叠区块的界面，避免逐字刷新。合并后的数据块 Type 为 "thinking"，
+// Content 为完整的思考过程，其余字段取自第一个思考数据块；没有思考过程时不会发送
+// 可以直接包装 Complete 的返回值：AggregateThinking(api.Complete(ctx, id, prompt, WithThinking()))；
+// 读取方需要读完返回的 chunkChan，提前结束时应取消原请求的 ctx 并继续读到通道关闭
+func AggregateThinking(chunkChan <-chan Chunk, errChan <-chan error) (<-chan Chunk, <-chan error) {
+	outChan := make(chan Chunk, 10)
+	outErrChan := make(chan error, 1)
+
+	go func() {
+		defer close(outChan)
+		defer close(outErrChan)
+
+		var thinking strings.Builder
+		var block Chunk
+		pending := false
+		flush := func() {
+			if !pending {
+				return
+			}
+			pending = false
+			block.Content = thinking.String()
+			thinking.Reset()
+			outChan <- block
+		}
+
+		for chunk := range chunkChan {
+			if chunk.Type == "restart" {
+				// 服务端重新生成了回答，还没有发送的思考过程属于被丢弃的回答
+				pending = false
+				thinking.Reset()
+			}
+			if chunk.Type == "thinking" {
+				if !pending && chunk.Content != "" {
+					block = chunk
+					pending = true
+				}
+				thinking.WriteString(chunk.Content)
+				if chunk.FinishReason == "" {
+					continue
+				}
+				// 带结束信号的思考数据块：内容已经合并，只转发结束信号
+				chunk.Content = ""
+			}
+			if chunk.Content != "" || chunk.FinishReason != "" || chunk.Type == "answer_start" {
+				flush()
+			}
+			outChan <- chunk
+		}
+		flush()
+
+		if err := <-errChan; err != nil {
+			outErrChan <- err
+		}
+	}()
+
+	return outChan, outErrChan
+}
@@ -0,0 +1,46 @@
+package dsk
+
+import "time"
+
+// Timings 一次回答中思考和回答两个阶段的时间，基于各阶段第一个和最后一个有内容的数据块的 ReceivedAt
+// 没有对应阶段时（例如未开启思考）该阶段的时间为零值
+type Timings struct {
+	ThinkingStart time.Time // 第一个思考数据块的接收时间
+	ThinkingEnd   time.Time // 最后一个思考数据块的接收时间
+	AnswerStart   time.Time // 第一个回答数据块的接收时间
+	AnswerEnd     time.Time // 最后一个回答数据块的接收时间
+}
+
+// ThinkingDuration 思考阶段的耗时，可以用于显示“已深度思考 8 秒”，没有思考过程时为 0
+func (t Timings) ThinkingDuration() time.Duration {
+	return t.ThinkingEnd.Sub(t.ThinkingStart)
+}
+
+// AnswerDuration 输出回答的耗时，没有回答内容时为 0
+func (t Timings) AnswerDuration() time.Duration {
+	return t.AnswerEnd.Sub(t.AnswerStart)
+}
+
+// record 根据数据块更新对应阶段的时间，没有内容的数据块不计入
+func (t *Timings) record(chunk Chunk) {
+	if chunk.Content == "" {
+		return
+	}
+	switch chunk.Type {
+	case "thinking":
+		if t.ThinkingStart.IsZero() {
+			t.ThinkingStart = chunk.ReceivedAt
+		}
+		t.ThinkingEnd = chunk.ReceivedAt
+	case "text":
+		if t.AnswerStart.IsZero() {
+			t.AnswerStart = chunk.ReceivedAt
+		}
+		t.AnswerEnd = chunk.ReceivedAt
+	}
+}
+
+// empty 是否没有记录任何阶段
+func (t Timings) empty() bool {
+	return t.ThinkingStart.IsZero() && t.AnswerStart.IsZero()
+}
@@ -0,0 +1,48 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+)
+
+// token 返回当前使用的 token，还没有 token 时从 tokenProvider 获取
+// 设置了 TokenSelector 时优先使用 ctx 中固定的 token，否则由选择器选择
+func (api *DeepSeekAPI) token(ctx context.Context) (string, error) {
+	if api.tokenSelector != nil {
+		if token, ok := ctx.Value(pinnedTokenKey{}).(string); ok {
+			return token, nil
+		}
+		return api.tokenSelector.Select(ctx)
+	}
+
+	api.tokenMu.RLock()
+	token := api.authToken
+	api.tokenMu.RUnlock()
+
+	if token != "" || api.tokenProvider == nil {
+		return token, nil
+	}
+	return api.refreshToken(ctx, "")
+}
+
+// refreshToken 从 tokenProvider 获取新的 token，stale 为刚刚失效的 token
+// 如果其他 goroutine 已经刷新过（当前 token 不再是 stale），直接返回当前 token，避免重复刷新
+func (api *DeepSeekAPI) refreshToken(ctx context.Context, stale string) (string, error) {
+	api.tokenMu.Lock()
+	defer api.tokenMu.Unlock()
+
+	if api.authToken != stale {
+		return api.authToken, nil
+	}
+
+	token, err := api.tokenProvider(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh token: %w", err)
+	}
+	if token == "" {
+		return "", fmt.Errorf("failed to refresh token: token provider returned an empty token")
+	}
+
+	api.authToken = token
+	return token, nil
+}
@@ -0,0 +1,191 @@
+package dsk
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// unauthorizedBench 返回 401 的 token 暂停使用的时间
+	unauthorizedBench = 10 * time.Minute
+	// rateLimitedBench 返回 429 的 token 暂停使用的时间
+	rateLimitedBench = time.Minute
+	// sessionTokenCacheSize 记住的会话与 token 对应关系的数量上限
+	sessionTokenCacheSize = 4096
+)
+
+// TokenSelector 多 token 客户端选择 token 的策略，实现必须可以并发调用
+type TokenSelector interface {
+	// Select 返回下一个请求使用的 token，没有可用 token 时返回错误
+	Select(ctx context.Context) (string, error)
+	// Report 在每个使用该 token 的请求收到响应后调用，statusCode 为 HTTP 状态码
+	Report(token string, statusCode int)
+}
+
+// RoundRobinSelector 轮流使用各个 token，返回 401 或 429 的 token 会暂停使用一段时间
+type RoundRobinSelector struct {
+	mu      sync.Mutex
+	tokens  []string
+	next    int
+	benched map[string]time.Time // token 恢复使用的时间
+}
+
+// NewRoundRobinSelector 创建轮询选择器，tokens 不能为空
+func NewRoundRobinSelector(tokens []string) *RoundRobinSelector {
+	return &RoundRobinSelector{
+		tokens:  append([]string(nil), tokens...),
+		benched: make(map[string]time.Time),
+	}
+}
+
+// Select 返回下一个没有被暂停的 token，全部被暂停时返回满足 errors.Is(err, ErrNoTokenAvailable) 的错误
+func (s *RoundRobinSelector) Select(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for i := 0; i < len(s.tokens); i++ {
+		token := s.tokens[(s.next+i)%len(s.tokens)]
+		if until, ok := s.benched[token]; ok {
+			if now.Before(until) {
+				continue
+			}
+			delete(s.benched, token)
+		}
+		s.next = (s.next + i + 1) % len(s.tokens)
+		return token, nil
+	}
+
+	return "", fmt.Errorf("%w: all %d tokens are benched", ErrNoTokenAvailable, len(s.tokens))
+}
+
+// Report 在 token 返回 401 时暂停使用 10 分钟，返回 429 时暂停使用 1 分钟
+func (s *RoundRobinSelector) Report(token string, statusCode int) {
+	var bench time.Duration
+	switch statusCode {
+	case http.StatusUnauthorized:
+		bench = unauthorizedBench
+	case http.StatusTooManyRequests:
+		bench = rateLimitedBench
+	default:
+		return
+	}
+
+	s.mu.Lock()
+	s.benched[token] = time.Now().Add(bench)
+	s.mu.Unlock()
+}
+
+// NewDeepSeekAPIWithTokens 创建使用多个账号 token 的客户端，默认按 RoundRobinSelector 轮流使用
+// 可以通过 WithTokenSelector 替换选择策略。
+//
+// 会话属于创建它的账号，PoW 挑战也与账号绑定，因此轮换的单位是会话而不是单个 HTTP 请求：
+// CreateChatSession 时选择 token 并记住会话与 token 的对应关系，之后该会话的 PoW 和对话都使用同一个 token；
+// 对不是由该客户端创建的会话发送消息时，每次调用选择一次 token。客户端最多记住最近使用的 4096 个会话，
+// 更早的会话按不是由该客户端创建的会话处理。BatchComplete 为每个请求创建新会话，
+// 因此请求会分散到各个账号上
+func NewDeepSeekAPIWithTokens(tokens []string, opts ...Option) (*DeepSeekAPI, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("tokens cannot be empty")
+	}
+	for i, token := range tokens {
+		if token == "" {
+			return nil, fmt.Errorf("token %d is empty", i)
+		}
+	}
+
+	return newDeepSeekAPI("", "", append([]Option{WithTokenSelector(NewRoundRobinSelector(tokens))}, opts...))
+}
+
+// pinnedTokenKey ctx 中固定使用的 token
+type pinnedTokenKey struct{}
+
+// pinToken 为 ctx 中的后续请求固定 token，未设置 TokenSelector 时原样返回
+// chatSessionID 为空或不是本客户端创建的会话时，重新选择一个 token
+func (api *DeepSeekAPI) pinToken(ctx context.Context, chatSessionID string) (context.Context, error) {
+	if api.tokenSelector == nil {
+		return ctx, nil
+	}
+	if _, ok := ctx.Value(pinnedTokenKey{}).(string); ok {
+		return ctx, nil
+	}
+
+	if chatSessionID != "" {
+		if token, ok := api.sessionTokens.get(chatSessionID); ok {
+			return context.WithValue(ctx, pinnedTokenKey{}, token), nil
+		}
+	}
+
+	token, err := api.tokenSelector.Select(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, pinnedTokenKey{}, token), nil
+}
+
+// rememberSessionToken 记录会话使用的 token，之后该会话的请求都使用它
+func (api *DeepSeekAPI) rememberSessionToken(ctx context.Context, chatSessionID string) {
+	if token, ok := ctx.Value(pinnedTokenKey{}).(string); ok {
+		api.sessionTokens.put(chatSessionID, token)
+	}
+}
+
+// sessionTokenEntry 会话与创建它的 token
+type sessionTokenEntry struct {
+	sessionID string
+	token     string
+}
+
+// sessionTokenCache 记录会话与 token 对应关系的 LRU 缓存，避免长期运行的客户端无限增长
+// 被淘汰的会话之后发送消息时会像不是由本客户端创建的会话一样重新选择 token
+type sessionTokenCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List // 最近使用的在前
+	entries map[string]*list.Element
+}
+
+// newSessionTokenCache 创建容量为 size 的缓存
+func newSessionTokenCache(size int) *sessionTokenCache {
+	return &sessionTokenCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get 返回会话对应的 token
+func (c *sessionTokenCache) get(sessionID string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[sessionID]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*sessionTokenEntry).token, true
+}
+
+// put 记录会话对应的 token，超过容量时淘汰最久未使用的会话
+func (c *sessionTokenCache) put(sessionID, token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[sessionID]; ok {
+		elem.Value.(*sessionTokenEntry).token = token
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[sessionID] = c.order.PushFront(&sessionTokenEntry{sessionID: sessionID, token: token})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*sessionTokenEntry).sessionID)
+	}
+}
@@ -0,0 +1,35 @@
+package dsk
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSessionTokenCacheBounded(t *testing.T) {
+	c := newSessionTokenCache(3)
+
+	c.put("s1", "t1")
+	c.put("s2", "t2")
+	c.put("s3", "t3")
+	// 使用 s1 后，最久未使用的是 s2
+	if token, ok := c.get("s1"); !ok || token != "t1" {
+		t.Fatalf("get(s1) = %q, %v", token, ok)
+	}
+	c.put("s4", "t4")
+
+	if _, ok := c.get("s2"); ok {
+		t.Fatal("s2 should have been evicted")
+	}
+	for _, id := range []string{"s1", "s3", "s4"} {
+		if _, ok := c.get(id); !ok {
+			t.Fatalf("%s should still be cached", id)
+		}
+	}
+
+	for i := 0; i < 1000; i++ {
+		c.put(fmt.Sprintf("session-%d", i), "t")
+	}
+	if n := c.order.Len(); n != 3 || len(c.entries) != 3 {
+		t.Fatalf("cache holds %d list entries and %d map entries, want 3", n, len(c.entries))
+	}
+}
@@ -0,0 +1,73 @@
+package dsk
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// EstimateTokens 粗略估算文本在 DeepSeek 分词器下的 token 数
+// 按 DeepSeek 官方给出的经验比例计算：1 个中日韩字符约 0.6 个 token，
+// 其他字符（英文字母、数字、标点、空白）约 0.3 个 token，结果向上取整
+// 对普通中英文混合文本误差通常在 ±20% 以内；代码、长串数字、URL 等
+// 分词效率较低的内容可能被低估 30% 以上，需要严格控制长度时应预留余量
+func EstimateTokens(text string) int {
+	var tenths int
+	for _, r := range text {
+		tenths += runeTokenTenths(r)
+	}
+	// 以十分之一 token 为单位计算，避免浮点误差影响取整
+	return (tenths + 9) / 10
+}
+
+// TruncateSide TruncatePrompt 裁剪文本的位置
+type TruncateSide int
+
+const (
+	// TruncateBack 保留开头，裁掉末尾超出的部分
+	TruncateBack TruncateSide = iota
+	// TruncateFront 保留末尾，裁掉开头超出的部分，适合保留最近的对话记录
+	TruncateFront
+)
+
+// TruncatePrompt 按 EstimateTokens 的估算把文本裁剪到 maxTokens 以内
+// 只在字符边界处裁剪，不会截断多字节字符；maxTokens 小于等于 0 时返回原文本
+func TruncatePrompt(text string, maxTokens int, side TruncateSide) string {
+	if maxTokens <= 0 || EstimateTokens(text) <= maxTokens {
+		return text
+	}
+
+	budget := maxTokens * 10
+	if side == TruncateFront {
+		end := len(text)
+		for end > 0 {
+			r, size := utf8.DecodeLastRuneInString(text[:end])
+			if budget -= runeTokenTenths(r); budget < 0 {
+				break
+			}
+			end -= size
+		}
+		return text[end:]
+	}
+
+	for i, r := range text {
+		if budget -= runeTokenTenths(r); budget < 0 {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// runeTokenTenths 返回单个字符估算的 token 数，以十分之一 token 为单位
+func runeTokenTenths(r rune) int {
+	if isCJK(r) {
+		return 6
+	}
+	return 3
+}
+
+// isCJK 判断字符是否为中日韩文字或全角标点
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || // 中日韩标点
+		(r >= 0xFF00 && r <= 0xFFEF) // 全角字符
+}
@@ -0,0 +1,123 @@
+package dsk
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// http2ReadIdleTimeout HTTP/2 连接上多久没有收到数据时发送 PING 检查连接
+	// 流式响应可能长时间没有数据，健康检查能及时发现已经断开的连接，而不是一直等待
+	http2ReadIdleTimeout = 30 * time.Second
+	// http2PingTimeout 发送 PING 后等待响应的时间，超时后关闭连接
+	http2PingTimeout = 15 * time.Second
+)
+
+// transportOptions WithTransportConfig、WithHTTP2 等选项对默认 HTTP 客户端的设置，
+// 在所有选项应用之后统一生效，因此与 WithHTTPClient 的先后顺序无关
+type transportOptions struct {
+	configured bool
+
+	maxIdleConns        int
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	http2               bool
+	dialContext         func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// newTransport 基于 http.DefaultTransport 创建应用了设置的 Transport
+func (o transportOptions) newTransport() (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if o.maxIdleConns > 0 {
+		t.MaxIdleConns = o.maxIdleConns
+	}
+	if o.maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = o.maxIdleConnsPerHost
+	}
+	if o.idleConnTimeout > 0 {
+		t.IdleConnTimeout = o.idleConnTimeout
+	}
+	if o.dialContext != nil {
+		t.DialContext = o.dialContext
+	}
+	if o.http2 {
+		// 在拨号函数设置之后注册，HTTP/2 连接同样使用它
+		t2, err := http2.ConfigureTransports(t)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure HTTP/2: %w", err)
+		}
+		t2.ReadIdleTimeout = http2ReadIdleTimeout
+		t2.PingTimeout = http2PingTimeout
+	}
+	return t, nil
+}
+
+// WithHTTPClient 使用调用方提供的 HTTP 客户端发送所有请求，例如需要代理或自定义 TLS 配置时
+// 流式响应可能持续数分钟，c.Timeout 应当为 0 或足够大；设置后 WithTransportConfig 不再生效
+func WithHTTPClient(c *http.Client) Option {
+	return func(api *DeepSeekAPI) {
+		if c != nil {
+			api.client = c
+			api.customClient = true
+		}
+	}
+}
+
+// WithTransportConfig 调整默认 HTTP 客户端的连接池，适合大量并发请求的批处理任务
+// 默认的 Transport 对每个主机只保留 2 个空闲连接，并发较高时连接会被频繁关闭重建；
+// 为 0 的参数保留默认值。连接池设置不影响流式响应；设置了 WithHTTPClient 时不生效
+func WithTransportConfig(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) Option {
+	return func(api *DeepSeekAPI) {
+		api.transport.configured = true
+		api.transport.maxIdleConns = maxIdle
+		api.transport.maxIdleConnsPerHost = maxIdlePerHost
+		api.transport.idleConnTimeout = idleTimeout
+	}
+}
+
+// WithHTTP2 通过 golang.org/x/net/http2 为默认 HTTP 客户端配置 HTTP/2，多个并发请求（包括流式响应）复用同一个连接
+// 与标准库内置的实现相比，连接空闲 30 秒后会发送 PING 检查，长时间没有数据的流式响应能及时发现断开的连接；
+// WithDialContext 的设置同样用于 HTTP/2 连接。服务端不支持时通过 TLS 协商回退到 HTTP/1.1。
+// 设置了 WithHTTPClient 时不生效，需要自行配置传入的客户端
+func WithHTTP2() Option {
+	return func(api *DeepSeekAPI) {
+		api.transport.configured = true
+		api.transport.http2 = true
+	}
+}
+
+// WithDialContext 设置默认 HTTP 客户端建立连接使用的函数，例如固定 DNS 解析结果、
+// 使用自定义解析器或通过服务网格的 sidecar 连接；addr 为 "host:port" 形式的目标地址
+// 与 WithHTTPClient 互斥，同时设置时创建客户端失败
+func WithDialContext(fn func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(api *DeepSeekAPI) {
+		api.transport.configured = true
+		api.transport.dialContext = fn
+	}
+}
+
+// configureTransport 在应用所有选项之后设置默认 HTTP 客户端的 Transport
+func (api *DeepSeekAPI) configureTransport() error {
+	if !api.transport.configured {
+		return nil
+	}
+	if api.customClient {
+		// 忽略拨号函数会让请求绕过调用方要求的解析或代理，直接报错
+		if api.transport.dialContext != nil {
+			return fmt.Errorf("WithDialContext cannot be used together with WithHTTPClient")
+		}
+		api.log.Warnf("Transport options are ignored because WithHTTPClient is set")
+		return nil
+	}
+	t, err := api.transport.newTransport()
+	if err != nil {
+		return err
+	}
+	api.client.Transport = t
+	return nil
+}
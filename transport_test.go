@@ -0,0 +1,148 @@
+package dsk_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+	"golang.org/x/net/http2"
+)
+
+// newTLSServer 启动开启 HTTP/2 的 TLS 模拟服务器，wrap 不为 nil 时用它包装 handler
+// 返回的计数器记录服务器接受的连接数
+func newTLSServer(tb testing.TB, opts dsktest.MockOptions, wrap func(http.Handler) http.Handler) (*httptest.Server, *atomic.Int64) {
+	tb.Helper()
+	handler := dsktest.NewHandler(opts)
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	conns := new(atomic.Int64)
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.StartTLS()
+	tb.Cleanup(srv.Close)
+	return srv, conns
+}
+
+// serverTLSConfig 返回信任 srv 证书的 TLS 配置
+func serverTLSConfig(srv *httptest.Server) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	return &tls.Config{RootCAs: pool}
+}
+
+// http2Client 返回信任 srv 证书、与 WithHTTP2 一样通过 golang.org/x/net/http2 配置的客户端
+func http2Client(tb testing.TB, srv *httptest.Server) *http.Client {
+	tb.Helper()
+	t := &http.Transport{TLSClientConfig: serverTLSConfig(srv)}
+	if _, err := http2.ConfigureTransports(t); err != nil {
+		tb.Fatalf("ConfigureTransports: %v", err)
+	}
+	return &http.Client{Transport: t}
+}
+
+// gatedWriter 第一次 Flush 之后阻塞，直到 release 关闭
+// 客户端必须在服务器继续写入之前收到第一个事件，用于确认事件被逐个刷出而不是缓冲到响应结束
+type gatedWriter struct {
+	http.ResponseWriter
+	flushes int
+	release <-chan struct{}
+}
+
+func (w *gatedWriter) Flush() {
+	w.ResponseWriter.(http.Flusher).Flush()
+	w.flushes++
+	if w.flushes == 1 {
+		<-w.release
+	}
+}
+
+func TestHTTP2StreamsSSE(t *testing.T) {
+	release := make(chan struct{})
+	var protoMajor atomic.Int32
+	srv, _ := newTLSServer(t, dsktest.MockOptions{
+		Events: []string{dsktest.TextEvent("first"), dsktest.TextEvent(" second"), dsktest.StopEvent(), dsktest.DoneEvent},
+	}, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/chat/completion") {
+				protoMajor.Store(int32(r.ProtoMajor))
+				w = &gatedWriter{ResponseWriter: w, release: release}
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	api := newTestClient(t, srv, dsk.WithHTTPClient(http2Client(t, srv)))
+
+	chunkChan, errChan := api.Complete(context.Background(), dsktest.SessionID, "hi")
+	select {
+	case chunk, ok := <-chunkChan:
+		close(release)
+		if !ok || chunk.Content != "first" {
+			t.Fatalf("first chunk = %+v (open %v), want content %q", chunk, ok, "first")
+		}
+	case <-time.After(5 * time.Second):
+		close(release)
+		t.Fatal("first event was not delivered before the server finished the response")
+	}
+
+	chunks, err := drain(chunkChan, errChan)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := answerText(chunks); got != " second" {
+		t.Fatalf("remaining text = %q, want %q", got, " second")
+	}
+	if got := protoMajor.Load(); got != 2 {
+		t.Fatalf("completion used HTTP/%d, want HTTP/2", got)
+	}
+}
+
+// BenchmarkConcurrentCompletions 对比并发请求在 HTTP/1.1 和 HTTP/2 下建立的连接数，
+// conns/op 为每个请求平均新建的连接数
+func BenchmarkConcurrentCompletions(b *testing.B) {
+	clients := map[string]func(tb testing.TB, srv *httptest.Server) []dsk.Option{
+		"http1.1": func(_ testing.TB, srv *httptest.Server) []dsk.Option {
+			// 非 nil 的空 TLSNextProto 关闭 HTTP/2
+			return []dsk.Option{dsk.WithHTTPClient(&http.Client{Transport: &http.Transport{
+				TLSClientConfig: serverTLSConfig(srv),
+				TLSNextProto:    map[string]func(string, *tls.Conn) http.RoundTripper{},
+			}})}
+		},
+		"http2": func(tb testing.TB, srv *httptest.Server) []dsk.Option {
+			return []dsk.Option{dsk.WithHTTPClient(http2Client(tb, srv))}
+		},
+	}
+
+	for _, name := range []string{"http1.1", "http2"} {
+		b.Run(name, func(b *testing.B) {
+			srv, conns := newTLSServer(b, dsktest.MockOptions{}, nil)
+			api := newTestClient(b, srv, clients[name](b, srv)...)
+
+			b.ResetTimer()
+			b.SetParallelism(8)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := drain(api.Complete(context.Background(), dsktest.SessionID, "hi")); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+		})
+	}
+}
@@ -0,0 +1,311 @@
+package dsk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxUploadSize UploadFile 默认允许的最大文件大小，与网页版的单文件上限一致
+const DefaultMaxUploadSize int64 = 100 << 20
+
+// DefaultUploadTypes UploadFile 默认允许的 MIME 类型：文本、PDF、Office 文档和常见图片
+var DefaultUploadTypes = []string{
+	"text/*",
+	"application/pdf",
+	"application/json",
+	"application/xml",
+	"application/msword",
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"application/vnd.ms-excel",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"application/vnd.ms-powerpoint",
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+	"image/bmp",
+}
+
+// officeTypes Office 文档的扩展名对应的类型，Go 内置的类型表不包含这些扩展名，
+// 嗅探时又只能得到 application/zip 或 application/octet-stream
+var officeTypes = map[string]string{
+	".doc":  "application/msword",
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xls":  "application/vnd.ms-excel",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".ppt":  "application/vnd.ms-powerpoint",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// sniffLen http.DetectContentType 最多使用的字节数
+const sniffLen = 512
+
+// UploadedFile 上传成功后服务端返回的文件信息，ID 可以通过 WithRefFiles 在对话中引用
+// 服务端会在上传后异步解析文件，Status 为 "SUCCESS" 之前引用该文件可能会失败
+type UploadedFile struct {
+	ID       string `json:"id"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	Status   string `json:"status"`
+}
+
+// UploadOption UploadFile 的可选配置
+type UploadOption func(*uploadConfig)
+
+type uploadConfig struct {
+	size        int64 // 小于 0 表示未指定
+	contentType string
+	progress    func(sent, total int64)
+}
+
+// WithContentType 指定文件的 MIME 类型，不指定时根据文件扩展名判断，
+// 扩展名无法识别时根据文件开头的内容用 http.DetectContentType 判断
+func WithContentType(contentType string) UploadOption {
+	return func(c *uploadConfig) {
+		c.contentType = contentType
+	}
+}
+
+// WithFileSize 指定文件的字节数，用于无法自动获取大小的 io.Reader（例如网络流）
+// 指定的大小必须与实际读取到的字节数一致，否则请求会失败
+func WithFileSize(size int64) UploadOption {
+	return func(c *uploadConfig) {
+		c.size = size
+	}
+}
+
+// WithUploadProgress 上传过程中每发送一部分文件内容调用一次 fn，sent 为已发送的字节数，total 为文件大小
+// fn 在上传所在的 goroutine 中同步调用，应当尽快返回
+// 需要知道文件大小：r 为 *os.File、*bytes.Reader 等可以获取大小的类型，或者通过 WithFileSize 指定，
+// 否则 UploadFile 返回错误
+func WithUploadProgress(fn func(sent, total int64)) UploadOption {
+	return func(c *uploadConfig) {
+		c.progress = fn
+	}
+}
+
+// UploadFile 上传文件并返回文件信息，name 为显示在会话中的文件名
+// 文件内容从 r 中流式读取，不会整体载入内存；r 的大小可以确定时会设置 Content-Length
+// 上传前会检查文件大小和类型，超过 WithMaxUploadSize 时返回 ErrFileTooLarge，
+// 类型不在 WithAllowedUploadTypes 中时返回 ErrUnsupportedType；大小无法提前确定时，
+// 读取到的内容超过限制后上传会中止并返回 ErrFileTooLarge
+func (api *DeepSeekAPI) UploadFile(ctx context.Context, name string, r io.Reader, opts ...UploadOption) (UploadedFile, error) {
+	cfg := uploadConfig{size: -1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	size := cfg.size
+	if size < 0 {
+		if n, ok := readerSize(r); ok {
+			size = n
+		}
+	}
+	if cfg.progress != nil && size < 0 {
+		return UploadedFile{}, fmt.Errorf("file size is unknown, use WithFileSize to report upload progress")
+	}
+	if api.maxUploadSize > 0 && size > api.maxUploadSize {
+		return UploadedFile{}, fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrFileTooLarge, name, size, api.maxUploadSize)
+	}
+
+	// 需要嗅探类型时先读出文件开头，之后再拼回请求体
+	contentType := cfg.contentType
+	if contentType == "" {
+		contentType = extensionType(name)
+	}
+	var sniffed []byte
+	if contentType == "" {
+		sniffed = make([]byte, sniffLen)
+		n, err := io.ReadFull(r, sniffed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return UploadedFile{}, fmt.Errorf("failed to read file: %w", err)
+		}
+		sniffed = sniffed[:n]
+		contentType = http.DetectContentType(sniffed)
+	}
+	if !uploadTypeAllowed(contentType, api.allowedUploadTypes) {
+		return UploadedFile{}, fmt.Errorf("%w: %s (%s)", ErrUnsupportedType, contentType, name)
+	}
+
+	ctx, done, err := api.track(ctx)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+	defer done()
+
+	const endpoint = "/file/upload_file"
+	powResponse, err := api.solvePow(ctx, endpoint)
+	if err != nil {
+		return UploadedFile{}, err
+	}
+
+	// 先生成 multipart 的头部和结尾，文件内容直接从 r 读取，这样可以提前算出请求体长度
+	var head bytes.Buffer
+	mw := multipart.NewWriter(&head)
+	if _, err := mw.CreatePart(filePartHeader(name, contentType)); err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to create multipart body: %w", err)
+	}
+	headLen := head.Len()
+	if err := mw.Close(); err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to create multipart body: %w", err)
+	}
+	tail := head.Bytes()[headLen:]
+
+	var content io.Reader = r
+	if len(sniffed) > 0 {
+		content = io.MultiReader(bytes.NewReader(sniffed), r)
+	}
+	if api.maxUploadSize > 0 && size < 0 {
+		content = &limitReader{r: content, n: api.maxUploadSize}
+	}
+	if cfg.progress != nil {
+		content = &progressReader{r: content, total: size, fn: cfg.progress}
+	}
+	body := io.MultiReader(bytes.NewReader(head.Bytes()[:headLen]), content, bytes.NewReader(tail))
+
+	req, err := http.NewRequestWithContext(ctx, "POST", api.baseURL+endpoint, body)
+	if err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range api.getHeaders(powResponse) {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("content-type", mw.FormDataContentType())
+	if size >= 0 {
+		req.ContentLength = int64(headLen) + size + int64(len(tail))
+		req.Header.Set("x-file-size", fmt.Sprint(size))
+	}
+
+	api.log.Debugf("Uploading %s (%d bytes)", name, size)
+	resp, err := api.do(req, endpoint)
+	if err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		api.log.Warnf("Request to %s failed: status %d", endpoint, resp.StatusCode)
+		return UploadedFile{}, newAPIError(endpoint, resp, respBody)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return UploadedFile{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	var file UploadedFile
+	if err := decodeBizData(result, &file); err != nil {
+		return UploadedFile{}, err
+	}
+	return file, nil
+}
+
+// filePartHeader 返回文件字段的 multipart 头，与 multipart.Writer.CreateFormFile 相同但使用指定的类型
+func filePartHeader(name, contentType string) textproto.MIMEHeader {
+	escape := strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, escape.Replace(name)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// extensionType 根据文件扩展名判断类型，无法识别时返回空字符串
+func extensionType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := officeTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// uploadTypeAllowed 判断 contentType 是否在 allowed 中，忽略参数（如 charset），allowed 为空时允许所有类型
+func uploadTypeAllowed(contentType string, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range allowed {
+		t = strings.ToLower(t)
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// readerSize 尽量获取 r 中剩余的字节数
+func readerSize(r io.Reader) (int64, bool) {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len()), true
+	case io.Seeker:
+		cur, err := v.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := v.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		if _, err := v.Seek(cur, io.SeekStart); err != nil {
+			return 0, false
+		}
+		return end - cur, true
+	case interface{ Stat() (fs.FileInfo, error) }:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		return info.Size(), true
+	}
+	return 0, false
+}
+
+// progressReader 统计已读取的字节数并回调上传进度
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
+
+// limitReader 读取超过 n 字节时返回 ErrFileTooLarge，用于大小无法提前确定的文件
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
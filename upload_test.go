@@ -0,0 +1,317 @@
+package dsk_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/minchieh-fay/dsk"
+	"github.com/minchieh-fay/dsk/dsktest"
+)
+
+// onlyReader 隐藏底层 reader 的 Len 等方法，模拟大小未知、只能读一次的文件流
+type onlyReader struct{ r io.Reader }
+
+func (o onlyReader) Read(p []byte) (int, error) { return o.r.Read(p) }
+
+func TestUploadFileUnauthorizedDoesNotRetryConsumedBody(t *testing.T) {
+	const freshToken = "fresh-token"
+	var uploads atomic.Int32
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/chat/create_pow_challenge", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"biz_data": map[string]interface{}{"challenge": dsktest.Challenge}},
+		})
+	})
+	mux.HandleFunc("/api/v0/file/upload_file", func(w http.ResponseWriter, r *http.Request) {
+		uploads.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("authorization") != "Bearer "+freshToken {
+			http.Error(w, `{"code":40003,"msg":"Authorization Failed (invalid token)"}`, http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(string(body), "file content") {
+			t.Errorf("upload body is missing the file content: %q", body)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"biz_data": map[string]interface{}{"id": "file-1", "file_name": "a.txt"}},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var refreshes atomic.Int32
+	api, err := dsk.NewDeepSeekAPI("stale-token",
+		dsk.WithBaseURL(dsktest.BaseURL(srv)),
+		dsk.WithPoWSolver(dsktest.StubSolver{}),
+		dsk.WithLogLevel(dsk.LevelOff),
+		dsk.WithTokenProvider(func(ctx context.Context) (string, error) {
+			refreshes.Add(1)
+			return freshToken, nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer api.Close(context.Background())
+
+	ctx := context.Background()
+
+	// 请求体已经被 401 的请求读完，不能带着空的请求体重发
+	_, err = api.UploadFile(ctx, "a.txt", onlyReader{strings.NewReader("file content")})
+	if !errors.Is(err, dsk.ErrUnauthorized) {
+		t.Fatalf("first upload error = %v, want ErrUnauthorized", err)
+	}
+	if n := uploads.Load(); n != 1 {
+		t.Fatalf("server received %d uploads, want 1 (no retry)", n)
+	}
+	if n := refreshes.Load(); n != 1 {
+		t.Fatalf("token provider called %d times, want 1", n)
+	}
+
+	// token 已经刷新，重新上传成功
+	file, err := api.UploadFile(ctx, "a.txt", onlyReader{strings.NewReader("file content")})
+	if err != nil {
+		t.Fatalf("second upload: %v", err)
+	}
+	if file.ID != "file-1" {
+		t.Fatalf("file ID = %q, want file-1", file.ID)
+	}
+}
+
+// receivedFile 模拟上传接口收到的文件
+type receivedFile struct {
+	Name        string
+	ContentType string
+	Content     string
+}
+
+// uploadServer 在 dsktest 模拟服务器上增加上传接口，记录收到的文件
+type uploadServer struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	files []receivedFile
+}
+
+func newUploadServer(t *testing.T) *uploadServer {
+	t.Helper()
+	us := &uploadServer{}
+	handler := dsktest.NewHandler(dsktest.MockOptions{})
+	us.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/file/upload_file") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		us.mu.Lock()
+		us.files = append(us.files, receivedFile{Name: header.Filename, ContentType: header.Header.Get("Content-Type"), Content: string(content)})
+		us.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code": 0,
+			"data": map[string]interface{}{"biz_data": map[string]interface{}{
+				"id": "file-1", "file_name": header.Filename, "file_size": len(content), "status": "PENDING",
+			}},
+		})
+	}))
+	t.Cleanup(us.Close)
+	return us
+}
+
+// received 返回收到的文件
+func (us *uploadServer) received() []receivedFile {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return append([]receivedFile(nil), us.files...)
+}
+
+func TestUploadFileProgress(t *testing.T) {
+	content := strings.Repeat("0123456789", 10000)
+	tests := []struct {
+		name string
+		r    io.Reader
+		opts []dsk.UploadOption
+	}{
+		{"known size", strings.NewReader(content), nil},
+		{"WithFileSize", onlyReader{strings.NewReader(content)}, []dsk.UploadOption{dsk.WithFileSize(int64(len(content)))}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newUploadServer(t)
+			api := newTestClient(t, srv.Server)
+
+			var calls int
+			var lastSent, lastTotal int64
+			opts := append(tt.opts, dsk.WithUploadProgress(func(sent, total int64) {
+				if sent < lastSent {
+					t.Errorf("progress went backwards: %d after %d", sent, lastSent)
+				}
+				calls++
+				lastSent, lastTotal = sent, total
+			}))
+			if _, err := api.UploadFile(context.Background(), "a.txt", tt.r, opts...); err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			if calls == 0 {
+				t.Fatal("progress callback was never called")
+			}
+			if lastSent != lastTotal || lastTotal != int64(len(content)) {
+				t.Fatalf("final progress = %d/%d, want %d/%d", lastSent, lastTotal, len(content), len(content))
+			}
+			if files := srv.received(); len(files) != 1 || files[0].Content != content {
+				t.Fatalf("server received %d files, want the full content", len(files))
+			}
+		})
+	}
+}
+
+func TestUploadFileProgressNeedsSize(t *testing.T) {
+	srv := newUploadServer(t)
+	api := newTestClient(t, srv.Server)
+
+	_, err := api.UploadFile(context.Background(), "a.txt", onlyReader{strings.NewReader("data")},
+		dsk.WithUploadProgress(func(sent, total int64) {}))
+	if err == nil || !strings.Contains(err.Error(), "WithFileSize") {
+		t.Fatalf("UploadFile error = %v, want a hint to use WithFileSize", err)
+	}
+	if n := len(srv.received()); n != 0 {
+		t.Fatalf("server received %d files, want none", n)
+	}
+}
+
+// sizedReader 报告 size 字节但不实际保存内容，用于测试超过大小上限的文件而不分配内存
+type sizedReader struct{ size int64 }
+
+func (s sizedReader) Len() int                   { return int(s.size) }
+func (s sizedReader) Read(p []byte) (int, error) { return 0, io.EOF }
+
+func TestUploadFileSizeLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientOpts []dsk.Option
+		r          io.Reader
+		wantErr    bool
+	}{
+		{"default limit", nil, sizedReader{dsk.DefaultMaxUploadSize + 1}, true},
+		{"custom limit", []dsk.Option{dsk.WithMaxUploadSize(10)}, strings.NewReader("0123456789a"), true},
+		{"at the limit", []dsk.Option{dsk.WithMaxUploadSize(10)}, strings.NewReader("0123456789"), false},
+		{"unlimited", []dsk.Option{dsk.WithMaxUploadSize(0)}, strings.NewReader(strings.Repeat("x", 4096)), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newUploadServer(t)
+			api := newTestClient(t, srv.Server, tt.clientOpts...)
+
+			_, err := api.UploadFile(context.Background(), "a.txt", tt.r)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("UploadFile: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, dsk.ErrFileTooLarge) {
+				t.Fatalf("UploadFile error = %v, want ErrFileTooLarge", err)
+			}
+			// 大小已知时在发送之前拒绝
+			if n := len(srv.received()); n != 0 {
+				t.Fatalf("server received %d files, want none", n)
+			}
+		})
+	}
+}
+
+func TestUploadFileUnknownSizeOverflow(t *testing.T) {
+	srv := newUploadServer(t)
+	api := newTestClient(t, srv.Server, dsk.WithMaxUploadSize(1024))
+
+	// 大小无法提前确定，读取超过上限后中止上传；错误经过 Transport 和 *url.Error 后仍然可以识别
+	_, err := api.UploadFile(context.Background(), "a.txt", onlyReader{strings.NewReader(strings.Repeat("x", 64<<10))})
+	if !errors.Is(err, dsk.ErrFileTooLarge) {
+		t.Fatalf("UploadFile error = %v, want ErrFileTooLarge", err)
+	}
+	if n := len(srv.received()); n != 0 {
+		t.Fatalf("server received %d complete files, want none", n)
+	}
+
+	// 不超过上限的未知大小文件正常上传
+	if _, err := api.UploadFile(context.Background(), "a.txt", onlyReader{strings.NewReader("small")}); err != nil {
+		t.Fatalf("UploadFile within the limit: %v", err)
+	}
+}
+
+func TestUploadFileTypes(t *testing.T) {
+	pngHeader := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 32)
+	zipHeader := "PK\x03\x04" + strings.Repeat("\x00", 32)
+	tests := []struct {
+		name       string
+		clientOpts []dsk.Option
+		fileName   string
+		content    string
+		opts       []dsk.UploadOption
+		wantType   string // 服务端收到的类型，为空表示应当被拒绝
+	}{
+		{"text extension with charset", nil, "notes.txt", "hello", nil, "text/plain; charset=utf-8"},
+		{"text wildcard", nil, "data.csv", "a,b\n1,2\n", nil, "text/csv; charset=utf-8"},
+		{"explicit type with charset", nil, "notes", "# title", []dsk.UploadOption{dsk.WithContentType("text/markdown; charset=utf-8")}, "text/markdown; charset=utf-8"},
+		{"office document", nil, "report.docx", zipHeader, nil, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"sniffed text without extension", nil, "README", "plain text file", nil, "text/plain; charset=utf-8"},
+		{"sniffed image without extension", nil, "photo", pngHeader, nil, "image/png"},
+		{"sniffed zip without extension", nil, "archive", zipHeader, nil, ""},
+		{"explicit unsupported type", nil, "a.txt", "x", []dsk.UploadOption{dsk.WithContentType("application/x-msdownload")}, ""},
+		{"custom allow list", []dsk.Option{dsk.WithAllowedUploadTypes("image/*")}, "notes.txt", "hello", nil, ""},
+		{"no type check", []dsk.Option{dsk.WithAllowedUploadTypes()}, "archive", zipHeader, nil, "application/zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newUploadServer(t)
+			api := newTestClient(t, srv.Server, tt.clientOpts...)
+
+			// 隐藏大小，嗅探读出的开头需要拼回请求体
+			_, err := api.UploadFile(context.Background(), tt.fileName, onlyReader{strings.NewReader(tt.content)}, tt.opts...)
+			if tt.wantType == "" {
+				if !errors.Is(err, dsk.ErrUnsupportedType) {
+					t.Fatalf("UploadFile error = %v, want ErrUnsupportedType", err)
+				}
+				if n := len(srv.received()); n != 0 {
+					t.Fatalf("server received %d files, want none", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UploadFile: %v", err)
+			}
+			files := srv.received()
+			if len(files) != 1 {
+				t.Fatalf("server received %d files, want 1", len(files))
+			}
+			if files[0].ContentType != tt.wantType {
+				t.Fatalf("content type = %q, want %q", files[0].ContentType, tt.wantType)
+			}
+			if files[0].Name != tt.fileName || files[0].Content != tt.content {
+				t.Fatalf("server received %q with %q, want %q with %q", files[0].Name, files[0].Content, tt.fileName, tt.content)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package dsk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// UserProfile 当前 token 对应的账号信息
+type UserProfile struct {
+	ID           string // 用户 ID
+	Email        string // 邮箱，使用手机号注册时可能为空
+	MobileNumber string // 手机号，使用邮箱注册时可能为空
+	Username     string // 第三方登录（如微信）的昵称，可能为空
+	Plan         string // 账号套餐，网页版接口目前通常不返回，此时为空
+}
+
+// GetUserProfile 获取当前登录账号的信息
+// token 无效或已过期时返回的错误满足 errors.Is(err, ErrUnauthorized)
+func (api *DeepSeekAPI) GetUserProfile(ctx context.Context) (UserProfile, error) {
+	resp, err := api.makeRequest(ctx, "GET", "/users/current", nil, false)
+	if err != nil {
+		return UserProfile{}, err
+	}
+
+	var user struct {
+		ID           string `json:"id"`
+		Email        string `json:"email"`
+		MobileNumber string `json:"mobile_number"`
+		Plan         string `json:"plan"`
+		IDProfile    struct {
+			Name string `json:"name"`
+		} `json:"id_profile"`
+	}
+	if err := decodeBizData(resp, &user); err != nil {
+		return UserProfile{}, err
+	}
+
+	return UserProfile{
+		ID:           user.ID,
+		Email:        user.Email,
+		MobileNumber: user.MobileNumber,
+		Username:     user.IDProfile.Name,
+		Plan:         user.Plan,
+	}, nil
+}
+
+// ValidateToken 通过一次不需要 PoW 的轻量请求检查 token 是否可用
+// token 可用时返回 nil；token 无效或已过期时返回的错误满足 errors.Is(err, ErrUnauthorized)；
+// 网络错误等其他失败原样返回，此时无法判断 token 是否有效
+func (api *DeepSeekAPI) ValidateToken(ctx context.Context) error {
+	_, err := api.GetUserProfile(ctx)
+	return err
+}
+
+// Ping 检查与 DeepSeek 的连通性和认证状态，不会创建会话也不需要 PoW，适合用作就绪探针
+// 超时通过 ctx 控制（例如 context.WithTimeout）；失败时返回的错误与其他接口一致，
+// 可以通过 errors.Is 区分 ErrUnauthorized、ErrRateLimited、ErrServerError 等
+func (api *DeepSeekAPI) Ping(ctx context.Context) error {
+	_, err := api.makeRequest(ctx, "GET", "/users/current", nil, false)
+	return err
+}
+
+// Usage 当前账号的消息用量
+type Usage struct {
+	UsedMessages      int       // 当前周期已发送的消息数
+	RemainingMessages int       // 当前周期剩余的消息数
+	ResetAt           time.Time // 用量重置时间，未返回时为零值
+}
+
+// GetUsage 查询当前账号的消息用量，可以在发送耗时较长的请求前检查剩余额度
+// DeepSeek 并非对所有账号开放用量接口，此时返回的错误满足 errors.Is(err, ErrUsageUnavailable)
+func (api *DeepSeekAPI) GetUsage(ctx context.Context) (Usage, error) {
+	resp, err := api.makeRequest(ctx, "GET", "/users/usage", nil, false)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return Usage{}, fmt.Errorf("%w: %w", ErrUsageUnavailable, err)
+		}
+		return Usage{}, err
+	}
+
+	var usage struct {
+		Used      int   `json:"used"`
+		Remaining int   `json:"remaining"`
+		ResetAt   int64 `json:"reset_at"` // Unix 时间戳（秒）
+	}
+	if err := decodeBizData(resp, &usage); err != nil {
+		return Usage{}, fmt.Errorf("%w: %w", ErrUsageUnavailable, err)
+	}
+
+	result := Usage{
+		UsedMessages:      usage.Used,
+		RemainingMessages: usage.Remaining,
+	}
+	if usage.ResetAt > 0 {
+		result.ResetAt = time.Unix(usage.ResetAt, 0)
+	}
+	return result, nil
+}
@@ -1,3 +1,30 @@
 package dsk
 
-// 此文件保留用于未来可能的工具函数
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// decodeBizData 将 makeRequest 返回结果中的 data.biz_data 解码到 out
+func decodeBizData(resp map[string]interface{}, out interface{}) error {
+	data, ok := resp["data"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid response format: missing data")
+	}
+
+	bizData, ok := data["biz_data"]
+	if !ok || bizData == nil {
+		return fmt.Errorf("invalid response format: missing biz_data")
+	}
+
+	raw, err := json.Marshal(bizData)
+	if err != nil {
+		return fmt.Errorf("failed to re-encode biz_data: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("failed to decode biz_data: %w", err)
+	}
+
+	return nil
+}